@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/usmonzodasomon/time-tracker/internal/config"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+)
+
+// runImport creates users for the passports of a CSV file and prints the result of every row.
+func runImport(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: import <file.csv>")
+	}
+	if cfg.Storage == config.StorageMemory {
+		return errors.New("import is not supported by memory storage, users would be lost on exit")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("error opening import file: %w", err)
+	}
+	defer file.Close()
+
+	rows, err := model.ParseImportCSV(file)
+	if err != nil {
+		return fmt.Errorf("error parsing import file: %w", err)
+	}
+	if len(rows) > cfg.Import.MaxRows {
+		return fmt.Errorf("import file has %d rows, at most %d are allowed", len(rows), cfg.Import.MaxRows)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	container, closeStorage, err := openContainer(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+
+	report, err := container.Import.ImportUsers(ctx, rows, model.AuditMeta{Actor: "cli"})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "LINE\tPASSPORT\tSTATUS\tUSER ID\tERROR")
+	for _, r := range report.Results {
+		userID := ""
+		if r.UserID != 0 {
+			userID = fmt.Sprint(r.UserID)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", r.Line, r.Passport, r.Status, userID, r.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\ncreated: %d, duplicate: %d, lookup failed: %d, invalid: %d, failed: %d\n",
+		report.Created, report.Duplicate, report.LookupFailed, report.Invalid, report.Failed)
+	return nil
+}
@@ -1,19 +1,23 @@
 package main
 
 import (
-	"errors"
+	"context"
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"github.com/usmonzodasomon/time-tracker/internal/app"
+	"github.com/usmonzodasomon/time-tracker/internal/config"
 	"github.com/usmonzodasomon/time-tracker/internal/handler"
+	"github.com/usmonzodasomon/time-tracker/internal/metrics"
+	"github.com/usmonzodasomon/time-tracker/internal/migrate"
+	"github.com/usmonzodasomon/time-tracker/internal/tracing"
 	"github.com/usmonzodasomon/time-tracker/pkg/logger"
 	"github.com/usmonzodasomon/time-tracker/pkg/postgres"
+	"github.com/usmonzodasomon/time-tracker/pkg/sqlite"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
 )
 
 // @title time-tracker API
@@ -22,37 +26,134 @@ import (
 // @host localhost:8080
 // @BasePath /api
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file")
+	configPath := flag.String("config", os.Getenv("CONFIG_PATH"), "path to YAML config file")
+	storage := flag.String("storage", "", "storage backend: postgres, sqlite or memory (overrides STORAGE)")
+	flag.Usage = usage
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath, config.WithStorage(*storage))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	logger.InitLogger(cfg.Log)
+
+	if flag.Arg(0) == "import" {
+		if err := runImport(cfg, flag.Args()[1:]); err != nil {
+			logger.Logger.Error("import failed", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(cfg, flag.Args()[1:]); err != nil {
+			logger.Logger.Error("migration failed", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
 		return
 	}
-	dbConn, err := postgres.GetConnection(postgres.Config{
-		Host:     os.Getenv("POSTGRES_HOST"),
-		Port:     os.Getenv("POSTGRES_PORT"),
-		User:     os.Getenv("POSTGRES_USER"),
-		Password: os.Getenv("POSTGRES_PASSWORD"),
-		DBName:   os.Getenv("POSTGRES_DATABASE"),
-	})
-	defer postgres.CloseConnection(dbConn)
+
+	if err := run(cfg); err != nil {
+		logger.Logger.Error("service failed", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	logger.Logger.Info("service stopped")
+}
+
+func run(cfg *config.Config) error {
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
 	if err != nil {
-		log.Fatal("Failed to connect to database: ", err)
+		return fmt.Errorf("failed to init tracing: %w", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Logger.Error("error flushing traces", slog.String("error", err.Error()))
+		}
+	}()
 
-	logger.InitLogger(os.Getenv("GO_ENV"))
+	container, closeStorage, err := openContainer(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStorage()
+	if err := metrics.RegisterRunningTimers(container.TaskRepo.CountRunningTasks); err != nil {
+		return fmt.Errorf("failed to register running timers metric: %w", err)
+	}
 
 	router := gin.New()
-	handler.NewRouter(router, dbConn)
+	handler.NewRouter(router, container.Services, cfg)
 
-	go func() {
-		logger.Logger.Info(fmt.Sprintf("starting server on port %s", os.Getenv("PORT")))
-		if err := http.ListenAndServe(":"+os.Getenv("PORT"), router); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.Logger.Error("failed to start server", slog.String("error", err.Error()))
+	server := &http.Server{
+		Addr:         ":" + cfg.HTTP.Port,
+		Handler:      router,
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+	}
+
+	a := app.New(server, cfg.HTTP.ShutdownTimeout)
+	a.AddWorker(container.ImportJobs.Run)
+	return a.Run(context.Background())
+}
+
+// openContainer connects to the configured storage, applying migrations where they are managed
+// by the service, and returns the application dependencies along with a function closing the connection.
+func openContainer(cfg *config.Config) (*app.Container, func(), error) {
+	switch cfg.Storage {
+	case config.StorageMemory:
+		logger.Logger.Warn("using in-memory storage, data is lost on restart")
+		return app.NewMemoryContainer(cfg), func() {}, nil
+	case config.StorageSQLite:
+		dbConn, err := sqlite.GetConnection(cfg.SQLite)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		closeDB := func() {
+			if err := sqlite.CloseConnection(dbConn); err != nil {
+				logger.Logger.Error("error closing database connection", slog.String("error", err.Error()))
+			}
 		}
-	}()
 
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, syscall.SIGTERM, syscall.SIGINT)
-	<-done
+		// The database file belongs to this process only, so its schema is always kept up to date.
+		migrator, err := migrate.NewSQLite(dbConn.DB)
+		if err != nil {
+			closeDB()
+			return nil, nil, err
+		}
+		if err := migrator.Up(context.Background()); err != nil {
+			closeDB()
+			return nil, nil, fmt.Errorf("failed to apply migrations: %w", err)
+		}
+		return app.NewSQLiteContainer(dbConn, cfg), closeDB, nil
+	default:
+		dbConn, err := postgres.GetConnection(cfg.Postgres)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		closeDB := func() {
+			if err := postgres.CloseConnection(dbConn); err != nil {
+				logger.Logger.Error("error closing database connection", slog.String("error", err.Error()))
+			}
+		}
 
-	logger.Logger.Info("service stopped")
+		if cfg.Migrations.AutoMigrate {
+			migrator, err := migrate.New(dbConn.DB, cfg.Migrations.LockTimeout)
+			if err != nil {
+				closeDB()
+				return nil, nil, err
+			}
+			if err := migrator.Up(context.Background()); err != nil {
+				closeDB()
+				return nil, nil, fmt.Errorf("failed to apply migrations: %w", err)
+			}
+		}
+
+		if err := metrics.RegisterDB(dbConn); err != nil {
+			closeDB()
+			return nil, nil, fmt.Errorf("failed to register database metrics: %w", err)
+		}
+		return app.NewContainer(dbConn, cfg), closeDB, nil
+	}
 }
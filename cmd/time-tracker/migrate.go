@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/usmonzodasomon/time-tracker/internal/config"
+	"github.com/usmonzodasomon/time-tracker/internal/migrate"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"github.com/usmonzodasomon/time-tracker/pkg/postgres"
+	"github.com/usmonzodasomon/time-tracker/pkg/sqlite"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+)
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage:\n")
+	fmt.Fprintf(out, "  %s [flags]                         run the HTTP server\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [flags] migrate up              apply all pending migrations\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [flags] migrate down            roll back the latest migration\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [flags] migrate status          show the state of migrations\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [flags] migrate to <version>    migrate up or down to the version\n", os.Args[0])
+	fmt.Fprintf(out, "  %s [flags] import <file.csv>       create users for the passports of a CSV file\n", os.Args[0])
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// runMigrate executes a migrate subcommand against the configured database.
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		flag.Usage()
+		return errors.New("migrate command is required")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	migrator, closeDB, err := openMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx)
+	case "status":
+		return migrator.Status(ctx, os.Stdout)
+	case "to":
+		if len(args) != 2 {
+			return errors.New("usage: migrate to <version>")
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid migration version %q", args[1])
+		}
+		return migrator.To(ctx, version)
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+// openMigrator connects to the configured storage and returns a migrator for it
+// along with a function closing the connection.
+func openMigrator(cfg *config.Config) (*migrate.Migrator, func(), error) {
+	switch cfg.Storage {
+	case config.StoragePostgres:
+		dbConn, err := postgres.GetConnection(cfg.Postgres)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to connect to database: %w", err)
+		}
+		closeDB := func() {
+			if err := postgres.CloseConnection(dbConn); err != nil {
+				logger.Logger.Error("error closing database connection", slog.String("error", err.Error()))
+			}
+		}
+		migrator, err := migrate.New(dbConn.DB, cfg.Migrations.LockTimeout)
+		if err != nil {
+			closeDB()
+			return nil, nil, err
+		}
+		return migrator, closeDB, nil
+	case config.StorageSQLite:
+		dbConn, err := sqlite.GetConnection(cfg.SQLite)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		closeDB := func() {
+			if err := sqlite.CloseConnection(dbConn); err != nil {
+				logger.Logger.Error("error closing database connection", slog.String("error", err.Error()))
+			}
+		}
+		migrator, err := migrate.NewSQLite(dbConn.DB)
+		if err != nil {
+			closeDB()
+			return nil, nil, err
+		}
+		return migrator, closeDB, nil
+	default:
+		return nil, nil, fmt.Errorf("migrations are not supported by %s storage", cfg.Storage)
+	}
+}
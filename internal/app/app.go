@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"net"
+	"net/http"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Worker is a background job which must return once its context is cancelled.
+type Worker func(ctx context.Context)
+
+type App struct {
+	server          *http.Server
+	shutdownTimeout time.Duration
+	workers         []Worker
+}
+
+func New(server *http.Server, shutdownTimeout time.Duration) *App {
+	return &App{server: server, shutdownTimeout: shutdownTimeout}
+}
+
+// AddWorker registers a background worker that runs for the lifetime of the app.
+func (a *App) AddWorker(w Worker) {
+	a.workers = append(a.workers, w)
+}
+
+// Run listens on the server address and serves until ctx is cancelled or SIGINT/SIGTERM is received.
+func (a *App) Run(ctx context.Context) error {
+	ln, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", a.server.Addr, err)
+	}
+	return a.Serve(ctx, ln)
+}
+
+// Serve serves on ln until ctx is cancelled or SIGINT/SIGTERM is received.
+// On shutdown it stops accepting connections, waits up to the shutdown timeout
+// for in-flight requests to finish and then stops the background workers.
+func (a *App) Serve(ctx context.Context, ln net.Listener) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	workersCtx, cancelWorkers := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancelWorkers()
+		wg.Wait()
+	}()
+	for _, w := range a.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w(workersCtx)
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Logger.Info(fmt.Sprintf("starting server on %s", ln.Addr()))
+		serveErr <- a.server.Serve(ln)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("error serving http: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Logger.Info("shutting down server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout)
+	defer cancel()
+	if err := a.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error shutting down server: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/usmonzodasomon/time-tracker/internal/config"
+	"github.com/usmonzodasomon/time-tracker/internal/external_api"
+	"github.com/usmonzodasomon/time-tracker/internal/external_api/mocks"
+	"github.com/usmonzodasomon/time-tracker/internal/handler"
+	"github.com/usmonzodasomon/time-tracker/internal/health"
+	"github.com/usmonzodasomon/time-tracker/internal/migrate"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/memory"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/sqlite"
+	"github.com/usmonzodasomon/time-tracker/internal/service"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"log/slog"
+	"net/http"
+)
+
+// Container wires repositories, services and external clients of the application.
+type Container struct {
+	UserRepo  repository.UserRepoI
+	TaskRepo  repository.TaskRepoI
+	AuditRepo repository.AuditRepoI
+
+	// Import resolves passports and creates users synchronously, e.g. for the import command.
+	Import service.ImportServiceI
+	// ImportJobs runs imports requested over HTTP and must be started with app.App.AddWorker.
+	ImportJobs *service.ImportJobs
+
+	Services handler.Services
+}
+
+// NewContainer builds the dependencies backed by Postgres.
+func NewContainer(db *sqlx.DB, cfg *config.Config) *Container {
+	userRepo := repository.NewUserRepo(db, cfg.Postgres.QueryTimeout)
+	taskRepo := repository.NewTaskRepo(db, cfg.Postgres.QueryTimeout)
+	auditRepo := repository.NewAuditRepo(db, cfg.Postgres.QueryTimeout)
+	idempotencyRepo := repository.NewIdempotencyRepo(db, cfg.Postgres.QueryTimeout)
+	txManager := repository.NewTxManager(db)
+	externalAPI := newUserExternalInfo(cfg.ExternalAPI)
+	importService := service.NewImportService(userRepo, externalAPI, txManager, cfg.Import.Concurrency, cfg.Import.BatchSize)
+	importJobs := service.NewImportJobs(importService, cfg.Import.QueueSize, cfg.Import.JobTTL)
+
+	return &Container{
+		UserRepo:   userRepo,
+		TaskRepo:   taskRepo,
+		AuditRepo:  auditRepo,
+		Import:     importService,
+		ImportJobs: importJobs,
+		Services: handler.Services{
+			User:        service.NewUserService(userRepo, taskRepo, txManager),
+			Task:        service.NewTaskService(taskRepo, txManager),
+			Audit:       service.NewAuditService(auditRepo),
+			Search:      service.NewSearchService(userRepo, taskRepo),
+			ImportJobs:  importJobs,
+			Idempotency: service.NewIdempotencyService(idempotencyRepo, cfg.Idempotency.TTL, cfg.Idempotency.Lease),
+			ExternalAPI: externalAPI,
+			Health:      newHealthChecker(cfg, postgresChecks(db, cfg)...),
+		},
+	}
+}
+
+// NewSQLiteContainer builds the dependencies backed by a SQLite database.
+func NewSQLiteContainer(db *sqlx.DB, cfg *config.Config) *Container {
+	userRepo := sqlite.NewUserRepo(db, cfg.SQLite.QueryTimeout)
+	taskRepo := sqlite.NewTaskRepo(db, cfg.SQLite.QueryTimeout)
+	auditRepo := sqlite.NewAuditRepo(db, cfg.SQLite.QueryTimeout)
+	idempotencyRepo := sqlite.NewIdempotencyRepo(db, cfg.SQLite.QueryTimeout)
+	txManager := repository.NewTxManager(db)
+	externalAPI := newUserExternalInfo(cfg.ExternalAPI)
+	importService := service.NewImportService(userRepo, externalAPI, txManager, cfg.Import.Concurrency, cfg.Import.BatchSize)
+	importJobs := service.NewImportJobs(importService, cfg.Import.QueueSize, cfg.Import.JobTTL)
+
+	return &Container{
+		UserRepo:   userRepo,
+		TaskRepo:   taskRepo,
+		AuditRepo:  auditRepo,
+		Import:     importService,
+		ImportJobs: importJobs,
+		Services: handler.Services{
+			User:        service.NewUserService(userRepo, taskRepo, txManager),
+			Task:        service.NewTaskService(taskRepo, txManager),
+			Audit:       service.NewAuditService(auditRepo),
+			Search:      service.NewSearchService(userRepo, taskRepo),
+			ImportJobs:  importJobs,
+			Idempotency: service.NewIdempotencyService(idempotencyRepo, cfg.Idempotency.TTL, cfg.Idempotency.Lease),
+			ExternalAPI: externalAPI,
+			Health:      newHealthChecker(cfg, health.Check{Name: "sqlite", Required: true, Fn: health.PingDB(db)}),
+		},
+	}
+}
+
+// NewMemoryContainer builds the dependencies backed by in-memory storage.
+func NewMemoryContainer(cfg *config.Config) *Container {
+	store := memory.NewStore()
+	userRepo := memory.NewUserRepo(store)
+	taskRepo := memory.NewTaskRepo(store)
+	auditRepo := memory.NewAuditRepo(store)
+	idempotencyRepo := memory.NewIdempotencyRepo(store)
+	txManager := memory.NewTxManager(store)
+	externalAPI := newUserExternalInfo(cfg.ExternalAPI)
+	importService := service.NewImportService(userRepo, externalAPI, txManager, cfg.Import.Concurrency, cfg.Import.BatchSize)
+	importJobs := service.NewImportJobs(importService, cfg.Import.QueueSize, cfg.Import.JobTTL)
+
+	return &Container{
+		UserRepo:   userRepo,
+		TaskRepo:   taskRepo,
+		AuditRepo:  auditRepo,
+		Import:     importService,
+		ImportJobs: importJobs,
+		Services: handler.Services{
+			User:        service.NewUserService(userRepo, taskRepo, txManager),
+			Task:        service.NewTaskService(taskRepo, txManager),
+			Audit:       service.NewAuditService(auditRepo),
+			Search:      service.NewSearchService(userRepo, taskRepo),
+			ImportJobs:  importJobs,
+			Idempotency: service.NewIdempotencyService(idempotencyRepo, cfg.Idempotency.TTL, cfg.Idempotency.Lease),
+			ExternalAPI: externalAPI,
+			Health:      newHealthChecker(cfg),
+		},
+	}
+}
+
+func newUserExternalInfo(cfg config.ExternalAPIConfig) external_api.UserExternalInfoI {
+	if cfg.Mock {
+		return external_api.NewInstrumentedUserExternalInfo(mocks.NewUserExternalInfo())
+	}
+	client := external_api.NewUserExternalInfo(&http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}, cfg.URL)
+	return external_api.NewInstrumentedUserExternalInfo(client)
+}
+
+func postgresChecks(db *sqlx.DB, cfg *config.Config) []health.Check {
+	minVersion := cfg.Health.MinMigrationVersion
+	if minVersion == 0 {
+		// By default the service is ready only with the schema it was built with.
+		latest, err := migrate.Latest()
+		if err != nil {
+			logger.Logger.Error("error reading embedded migrations", slog.String("error", err.Error()))
+		}
+		minVersion = latest
+	}
+
+	return []health.Check{
+		{Name: "postgres", Required: true, Fn: health.PingDB(db)},
+		{Name: "migrations", Required: true, Fn: health.MigrationVersion(db, minVersion)},
+	}
+}
+
+func newHealthChecker(cfg *config.Config, checks ...health.Check) *health.Checker {
+	if cfg.Health.CheckExternalAPI && !cfg.ExternalAPI.Mock {
+		externalApi := external_api.NewUserExternalInfo(&http.Client{Timeout: cfg.ExternalAPI.Timeout}, cfg.ExternalAPI.URL)
+		checks = append(checks, health.Check{
+			Name: "external_api",
+			Fn: func(ctx context.Context) (string, error) {
+				return "", externalApi.Ping(ctx)
+			},
+		})
+	}
+	return health.NewChecker(cfg.Health.Timeout, checks...)
+}
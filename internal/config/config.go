@@ -0,0 +1,375 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"github.com/usmonzodasomon/time-tracker/internal/tracing"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"github.com/usmonzodasomon/time-tracker/pkg/postgres"
+	"github.com/usmonzodasomon/time-tracker/pkg/sqlite"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"strconv"
+	"time"
+)
+
+type Config struct {
+	Env         string            `yaml:"env"`
+	HTTP        HTTPConfig        `yaml:"http"`
+	Postgres    postgres.Config   `yaml:"postgres"`
+	SQLite      sqlite.Config     `yaml:"sqlite"`
+	Log         logger.Config     `yaml:"log"`
+	ExternalAPI ExternalAPIConfig `yaml:"external_api"`
+	Health      HealthConfig      `yaml:"health"`
+	Tracing     tracing.Config    `yaml:"tracing"`
+	Migrations  MigrationsConfig  `yaml:"migrations"`
+	Import      ImportConfig      `yaml:"import"`
+	Idempotency IdempotencyConfig `yaml:"idempotency"`
+	Storage     string            `yaml:"storage"`
+	AdminToken  string            `yaml:"admin_token"`
+}
+
+const (
+	StoragePostgres = "postgres"
+	// StorageSQLite keeps data in a local file, it is meant for offline runs on a single machine.
+	StorageSQLite = "sqlite"
+	// StorageMemory keeps all data in process memory, it is meant for demo runs and tests.
+	StorageMemory = "memory"
+)
+
+type HTTPConfig struct {
+	Port            string        `yaml:"port"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type ExternalAPIConfig struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+	Mock    bool          `yaml:"mock"`
+}
+
+type HealthConfig struct {
+	Timeout             time.Duration `yaml:"timeout"`
+	CheckExternalAPI    bool          `yaml:"check_external_api"`
+	MinMigrationVersion int64         `yaml:"min_migration_version"`
+}
+
+type MigrationsConfig struct {
+	AutoMigrate bool          `yaml:"auto_migrate"`
+	LockTimeout time.Duration `yaml:"lock_timeout"`
+}
+
+// ImportConfig limits bulk user imports.
+type ImportConfig struct {
+	// Concurrency is the number of passports resolved through the external API at a time.
+	Concurrency int `yaml:"concurrency"`
+	// BatchSize is the number of users created in one transaction.
+	BatchSize int `yaml:"batch_size"`
+	// MaxRows is the largest number of rows accepted in one import.
+	MaxRows int `yaml:"max_rows"`
+	// QueueSize is the number of imports waiting for the running one to finish.
+	QueueSize int `yaml:"queue_size"`
+	// JobTTL is how long the report of a finished import can be polled.
+	JobTTL time.Duration `yaml:"job_ttl"`
+}
+
+type IdempotencyConfig struct {
+	// TTL is how long responses are replayed for retries with the same Idempotency-Key.
+	TTL time.Duration `yaml:"ttl"`
+	// Lease is how long a request keeps its Idempotency-Key without completing, e.g. after the process
+	// crashed, before the next request with the key takes it over. The HTTP write timeout does not stop
+	// a handler, so a slow request may still run after that, but it can no longer store its response
+	// or release the key. The lease is kept above the write timeout, so that takeovers stay rare.
+	Lease time.Duration `yaml:"lease"`
+}
+
+// Option overrides the loaded configuration, e.g. with command line flags.
+type Option func(*Config)
+
+// WithStorage overrides the storage backend when s is not empty.
+func WithStorage(s string) Option {
+	return func(c *Config) {
+		if s != "" {
+			c.Storage = s
+		}
+	}
+}
+
+// Load builds the configuration from defaults, the optional YAML file at path,
+// the optional .env file, environment variables and opts, in increasing order of priority.
+func Load(path string, opts ...Option) (*Config, error) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("error loading .env file: %w", err)
+	}
+
+	cfg := defaultConfig()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	cfg.Log.Env = cfg.Env
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Env:     "local",
+		Storage: StoragePostgres,
+		HTTP: HTTPConfig{
+			Port:            "8080",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			ShutdownTimeout: 15 * time.Second,
+		},
+		Postgres: postgres.Config{
+			Port:            "5432",
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			QueryTimeout:    5 * time.Second,
+		},
+		SQLite: sqlite.Config{
+			Path:         "time-tracker.db",
+			QueryTimeout: 5 * time.Second,
+		},
+		ExternalAPI: ExternalAPIConfig{
+			Timeout: 5 * time.Second,
+			Mock:    true,
+		},
+		Health: HealthConfig{
+			Timeout: 2 * time.Second,
+		},
+		Tracing: tracing.Config{
+			Exporter:    tracing.ExporterNone,
+			ServiceName: "time-tracker",
+			SampleRatio: 1,
+		},
+		Migrations: MigrationsConfig{
+			LockTimeout: 5 * time.Minute,
+		},
+		Import: ImportConfig{
+			Concurrency: 4,
+			BatchSize:   100,
+			MaxRows:     1000,
+			QueueSize:   10,
+			JobTTL:      time.Hour,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:   24 * time.Hour,
+			Lease: time.Minute,
+		},
+	}
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("error parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	setString(&c.Env, "GO_ENV")
+
+	setString(&c.HTTP.Port, "PORT")
+	setString(&c.Postgres.Host, "POSTGRES_HOST")
+	setString(&c.Postgres.Port, "POSTGRES_PORT")
+	setString(&c.Postgres.User, "POSTGRES_USER")
+	setString(&c.Postgres.Password, "POSTGRES_PASSWORD")
+	setString(&c.Postgres.DBName, "POSTGRES_DATABASE")
+	setString(&c.SQLite.Path, "SQLITE_PATH")
+	setString(&c.Log.Level, "LOG_LEVEL")
+	setString(&c.ExternalAPI.URL, "EXTERNAL_API_URL")
+	setString(&c.AdminToken, "ADMIN_TOKEN")
+	setString(&c.Storage, "STORAGE")
+	setString(&c.Tracing.Exporter, "TRACING_EXPORTER")
+	setString(&c.Tracing.OTLPEndpoint, "TRACING_OTLP_ENDPOINT")
+	setString(&c.Tracing.ServiceName, "TRACING_SERVICE_NAME")
+
+	return errors.Join(
+		setDuration(&c.HTTP.ReadTimeout, "HTTP_READ_TIMEOUT"),
+		setDuration(&c.HTTP.WriteTimeout, "HTTP_WRITE_TIMEOUT"),
+		setDuration(&c.HTTP.ShutdownTimeout, "HTTP_SHUTDOWN_TIMEOUT"),
+		setInt(&c.Postgres.MaxOpenConns, "POSTGRES_MAX_OPEN_CONNS"),
+		setInt(&c.Postgres.MaxIdleConns, "POSTGRES_MAX_IDLE_CONNS"),
+		setDuration(&c.Postgres.ConnMaxLifetime, "POSTGRES_CONN_MAX_LIFETIME"),
+		setDuration(&c.Postgres.QueryTimeout, "POSTGRES_QUERY_TIMEOUT"),
+		setDuration(&c.SQLite.QueryTimeout, "SQLITE_QUERY_TIMEOUT"),
+		setDuration(&c.ExternalAPI.Timeout, "EXTERNAL_API_TIMEOUT"),
+		setBool(&c.ExternalAPI.Mock, "EXTERNAL_API_MOCK"),
+		setDuration(&c.Health.Timeout, "HEALTH_CHECK_TIMEOUT"),
+		setBool(&c.Health.CheckExternalAPI, "HEALTH_CHECK_EXTERNAL_API"),
+		setInt64(&c.Health.MinMigrationVersion, "HEALTH_MIN_MIGRATION_VERSION"),
+		setFloat(&c.Tracing.SampleRatio, "TRACING_SAMPLE_RATIO"),
+		setBool(&c.Migrations.AutoMigrate, "AUTO_MIGRATE"),
+		setDuration(&c.Migrations.LockTimeout, "MIGRATIONS_LOCK_TIMEOUT"),
+		setInt(&c.Import.Concurrency, "IMPORT_CONCURRENCY"),
+		setInt(&c.Import.BatchSize, "IMPORT_BATCH_SIZE"),
+		setInt(&c.Import.MaxRows, "IMPORT_MAX_ROWS"),
+		setInt(&c.Import.QueueSize, "IMPORT_QUEUE_SIZE"),
+		setDuration(&c.Import.JobTTL, "IMPORT_JOB_TTL"),
+		setDuration(&c.Idempotency.TTL, "IDEMPOTENCY_TTL"),
+		setDuration(&c.Idempotency.Lease, "IDEMPOTENCY_LEASE"),
+	)
+}
+
+func (c *Config) validate() error {
+	var errs []error
+	required := func(value, name string) {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+	positive := func(value int64, name string) {
+		if value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+
+	required(c.HTTP.Port, "PORT")
+	switch c.Storage {
+	case StoragePostgres:
+		required(c.Postgres.Host, "POSTGRES_HOST")
+		required(c.Postgres.Port, "POSTGRES_PORT")
+		required(c.Postgres.User, "POSTGRES_USER")
+		required(c.Postgres.DBName, "POSTGRES_DATABASE")
+	case StorageSQLite:
+		required(c.SQLite.Path, "SQLITE_PATH")
+		positive(int64(c.SQLite.QueryTimeout), "SQLITE_QUERY_TIMEOUT")
+	case StorageMemory:
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE must be one of postgres, sqlite, memory: %q", c.Storage))
+	}
+	if !c.ExternalAPI.Mock {
+		required(c.ExternalAPI.URL, "EXTERNAL_API_URL")
+	}
+
+	positive(int64(c.HTTP.ReadTimeout), "HTTP_READ_TIMEOUT")
+	positive(int64(c.HTTP.WriteTimeout), "HTTP_WRITE_TIMEOUT")
+	positive(int64(c.HTTP.ShutdownTimeout), "HTTP_SHUTDOWN_TIMEOUT")
+	positive(int64(c.Postgres.MaxOpenConns), "POSTGRES_MAX_OPEN_CONNS")
+	positive(int64(c.Postgres.QueryTimeout), "POSTGRES_QUERY_TIMEOUT")
+	positive(int64(c.ExternalAPI.Timeout), "EXTERNAL_API_TIMEOUT")
+	positive(int64(c.Health.Timeout), "HEALTH_CHECK_TIMEOUT")
+	positive(int64(c.Migrations.LockTimeout), "MIGRATIONS_LOCK_TIMEOUT")
+	positive(int64(c.Import.Concurrency), "IMPORT_CONCURRENCY")
+	positive(int64(c.Import.BatchSize), "IMPORT_BATCH_SIZE")
+	positive(int64(c.Import.MaxRows), "IMPORT_MAX_ROWS")
+	positive(int64(c.Import.QueueSize), "IMPORT_QUEUE_SIZE")
+	positive(int64(c.Import.JobTTL), "IMPORT_JOB_TTL")
+	positive(int64(c.Idempotency.TTL), "IDEMPOTENCY_TTL")
+	if c.Idempotency.Lease <= c.HTTP.WriteTimeout {
+		errs = append(errs, errors.New("IDEMPOTENCY_LEASE must be longer than HTTP_WRITE_TIMEOUT"))
+	}
+	if c.Postgres.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("POSTGRES_MAX_IDLE_CONNS must not be negative"))
+	}
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		errs = append(errs, fmt.Errorf("TRACING_EXPORTER must be one of none, stdout, otlp: %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("TRACING_SAMPLE_RATIO must be between 0 and 1"))
+	}
+	if c.Log.Level != "" {
+		if _, err := logger.ParseLevel(c.Log.Level); err != nil {
+			errs = append(errs, fmt.Errorf("LOG_LEVEL: %w", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func setString(dst *string, key string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = v
+	}
+}
+
+func setInt(dst *int, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s must be an integer: %q", key, v)
+	}
+	*dst = n
+	return nil
+}
+
+func setInt64(dst *int64, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be an integer: %q", key, v)
+	}
+	*dst = n
+	return nil
+}
+
+func setFloat(dst *float64, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fmt.Errorf("%s must be a number: %q", key, v)
+	}
+	*dst = f
+	return nil
+}
+
+func setBool(dst *bool, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s must be a boolean: %q", key, v)
+	}
+	*dst = b
+	return nil
+}
+
+func setDuration(dst *time.Duration, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s must be a duration like 5s or 1m: %q", key, v)
+	}
+	*dst = d
+	return nil
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("POSTGRES_HOST", "localhost")
+	t.Setenv("POSTGRES_USER", "postgres")
+	t.Setenv("POSTGRES_DATABASE", "timetracker")
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	setRequiredEnv(t)
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.HTTP.Port != "8080" {
+		t.Errorf("expected default port 8080, got %s", cfg.HTTP.Port)
+	}
+	if cfg.Postgres.MaxOpenConns != 10 {
+		t.Errorf("expected default max open conns 10, got %d", cfg.Postgres.MaxOpenConns)
+	}
+	if !cfg.ExternalAPI.Mock {
+		t.Error("expected external API mock to be enabled by default")
+	}
+	if cfg.Import != (ImportConfig{Concurrency: 4, BatchSize: 100, MaxRows: 1000, QueueSize: 10, JobTTL: time.Hour}) {
+		t.Errorf("unexpected default import config %+v", cfg.Import)
+	}
+	if cfg.Log.Env != cfg.Env {
+		t.Errorf("expected logger env %s, got %s", cfg.Env, cfg.Log.Env)
+	}
+}
+
+func TestLoad_FileAndEnvPriority(t *testing.T) {
+	setRequiredEnv(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `
+env: prod
+http:
+  port: "9090"
+  read_timeout: 3s
+postgres:
+  host: db.internal
+  max_open_conns: 25
+external_api:
+  url: https://api.example.com
+  timeout: 2s
+  mock: false
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PORT", "7070")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.HTTP.Port != "7070" {
+		t.Errorf("expected env to override file port, got %s", cfg.HTTP.Port)
+	}
+	if cfg.HTTP.ReadTimeout != 3*time.Second {
+		t.Errorf("expected read timeout 3s, got %s", cfg.HTTP.ReadTimeout)
+	}
+	if cfg.Postgres.Host != "localhost" {
+		t.Errorf("expected env to override file host, got %s", cfg.Postgres.Host)
+	}
+	if cfg.Postgres.MaxOpenConns != 25 {
+		t.Errorf("expected max open conns 25, got %d", cfg.Postgres.MaxOpenConns)
+	}
+	if cfg.ExternalAPI.Mock || cfg.ExternalAPI.URL != "https://api.example.com" {
+		t.Errorf("unexpected external API config %+v", cfg.ExternalAPI)
+	}
+}
+
+func TestLoad_Validation(t *testing.T) {
+	t.Setenv("POSTGRES_HOST", "")
+	t.Setenv("POSTGRES_USER", "")
+	t.Setenv("POSTGRES_DATABASE", "")
+	t.Setenv("EXTERNAL_API_MOCK", "false")
+	t.Setenv("EXTERNAL_API_URL", "")
+	t.Setenv("EXTERNAL_API_TIMEOUT", "soon")
+
+	_, err := Load("")
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	if !strings.Contains(err.Error(), "EXTERNAL_API_TIMEOUT must be a duration") {
+		t.Errorf("expected duration parse error, got %v", err)
+	}
+
+	t.Setenv("EXTERNAL_API_TIMEOUT", "5s")
+	_, err = Load("")
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"POSTGRES_HOST is required", "POSTGRES_DATABASE is required", "EXTERNAL_API_URL is required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to mention %q, got %v", want, err)
+		}
+	}
+
+	setRequiredEnv(t)
+	t.Setenv("EXTERNAL_API_MOCK", "true")
+	t.Setenv("IDEMPOTENCY_LEASE", "10s")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "IDEMPOTENCY_LEASE must be longer than HTTP_WRITE_TIMEOUT") {
+		t.Errorf("expected lease validation error, got %v", err)
+	}
+}
+
+func TestLoad_MemoryStorage(t *testing.T) {
+	t.Setenv("POSTGRES_HOST", "")
+	t.Setenv("POSTGRES_USER", "")
+	t.Setenv("POSTGRES_DATABASE", "")
+	t.Setenv("STORAGE", "redis")
+
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "STORAGE must be one of") {
+		t.Fatalf("expected storage validation error, got %v", err)
+	}
+
+	cfg, err := Load("", WithStorage(StorageMemory))
+	if err != nil {
+		t.Fatalf("expected postgres settings to be optional for memory storage: %v", err)
+	}
+	if cfg.Storage != StorageMemory {
+		t.Errorf("expected flag to override env storage, got %s", cfg.Storage)
+	}
+}
+
+func TestLoad_SQLiteStorage(t *testing.T) {
+	t.Setenv("POSTGRES_HOST", "")
+	t.Setenv("STORAGE", "sqlite")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("expected postgres settings to be optional for sqlite storage: %v", err)
+	}
+	if cfg.SQLite.Path != "time-tracker.db" {
+		t.Errorf("expected default sqlite path, got %s", cfg.SQLite.Path)
+	}
+
+	t.Setenv("SQLITE_PATH", "")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "SQLITE_PATH is required") {
+		t.Errorf("expected sqlite path validation error, got %v", err)
+	}
+}
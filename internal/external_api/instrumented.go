@@ -0,0 +1,33 @@
+package external_api
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/metrics"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/tracing"
+	"time"
+)
+
+type instrumentedUserExternalInfo struct {
+	next UserExternalInfoI
+}
+
+// NewInstrumentedUserExternalInfo traces every call to next and records its outcome and latency.
+func NewInstrumentedUserExternalInfo(next UserExternalInfoI) UserExternalInfoI {
+	return &instrumentedUserExternalInfo{next: next}
+}
+
+func (u *instrumentedUserExternalInfo) GetUser(ctx context.Context, passportSerie, passportNumber int) (model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserExternalInfo.GetUser")
+	start := time.Now()
+	user, err := u.next.GetUser(ctx, passportSerie, passportNumber)
+	tracing.End(span, err)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	metrics.ExternalAPIRequests.WithLabelValues("get_user", outcome).Inc()
+	metrics.ExternalAPIRequestDuration.WithLabelValues("get_user").Observe(time.Since(start).Seconds())
+	return user, err
+}
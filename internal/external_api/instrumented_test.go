@@ -0,0 +1,36 @@
+package external_api
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/usmonzodasomon/time-tracker/internal/external_api/mocks"
+	"github.com/usmonzodasomon/time-tracker/internal/metrics"
+	"testing"
+)
+
+func TestInstrumentedUserExternalInfo(t *testing.T) {
+	ctx := context.Background()
+	api := NewInstrumentedUserExternalInfo(mocks.NewUserExternalInfo())
+	success := metrics.ExternalAPIRequests.WithLabelValues("get_user", "success")
+	failure := metrics.ExternalAPIRequests.WithLabelValues("get_user", "error")
+	successBefore, failureBefore := testutil.ToFloat64(success), testutil.ToFloat64(failure)
+	observedBefore := testutil.CollectAndCount(metrics.ExternalAPIRequestDuration)
+
+	user, err := api.GetUser(ctx, 1234, 5678)
+	if err != nil || user.Name != "Петр" {
+		t.Fatalf("expected user from the wrapped api, got %+v, %v", user, err)
+	}
+	if _, err := api.GetUser(ctx, 1, 1); err == nil {
+		t.Fatal("expected error for unknown passport")
+	}
+
+	if got := testutil.ToFloat64(success) - successBefore; got != 1 {
+		t.Errorf("expected 1 successful call, got %v", got)
+	}
+	if got := testutil.ToFloat64(failure) - failureBefore; got != 1 {
+		t.Errorf("expected 1 failed call, got %v", got)
+	}
+	if got := testutil.CollectAndCount(metrics.ExternalAPIRequestDuration); got != max(observedBefore, 1) {
+		t.Errorf("expected latency of get_user to be observed, got %d series", got)
+	}
+}
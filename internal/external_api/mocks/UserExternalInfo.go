@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"github.com/usmonzodasomon/time-tracker/internal/model"
 )
@@ -64,7 +65,7 @@ func NewUserExternalInfo() *UserExternalInfo {
 	}
 }
 
-func (u *UserExternalInfo) GetUser(passportSerie, passportNumber int) (model.User, error) {
+func (u *UserExternalInfo) GetUser(ctx context.Context, passportSerie, passportNumber int) (model.User, error) {
 	for _, user := range u.data {
 		if user.PassportSerie == passportSerie && user.PassportNumber == passportNumber {
 			return user, nil
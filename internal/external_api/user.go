@@ -1,33 +1,36 @@
 package external_api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/usmonzodasomon/time-tracker/internal/model"
 	"io"
 	"net/http"
-	"os"
 )
 
 type UserExternalInfoI interface {
-	GetUser(passportSerie, passportNumber int) (model.User, error)
+	GetUser(ctx context.Context, passportSerie, passportNumber int) (model.User, error)
 }
 
 type UserExternalInfo struct {
 	client *http.Client
+	url    string
 }
 
-func NewUserExternalInfo(client *http.Client) *UserExternalInfo {
-	return &UserExternalInfo{client: client}
+func NewUserExternalInfo(client *http.Client, url string) *UserExternalInfo {
+	return &UserExternalInfo{client: client, url: url}
 }
 
-var URL = os.Getenv("EXTERNAL_API_URL")
+func (u *UserExternalInfo) GetUser(ctx context.Context, passportSerie, passportNumber int) (model.User, error) {
+	url := fmt.Sprintf("%s/info?passportSerie=%d&passportNumber=%d", u.url, passportSerie, passportNumber)
 
-func (u *UserExternalInfo) GetUser(passportSerie, passportNumber int) (model.User, error) {
-	url := fmt.Sprintf("%s/info?passportSerie=%d&passportNumber=%d", URL, passportSerie, passportNumber)
-
-	resp, err := u.client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return model.User{}, err
+	}
+	resp, err := u.client.Do(req)
 	if err != nil {
 		return model.User{}, err
 	}
@@ -62,3 +65,21 @@ func (u *UserExternalInfo) GetUser(passportSerie, passportNumber int) (model.Use
 		Address:        user.Address,
 	}, nil
 }
+
+// Ping checks that the external API is reachable. Any response below 500 counts as reachable.
+func (u *UserExternalInfo) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("external api responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/service"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"log/slog"
+	"net/http"
+)
+
+type auditHandler struct {
+	service service.AuditServiceI
+}
+
+func newAuditHandler(handler *gin.RouterGroup, auditService service.AuditServiceI, adminToken string) {
+	r := &auditHandler{
+		service: auditService,
+	}
+
+	h := handler.Group("/audit", adminOnly(adminToken))
+	{
+		h.GET("/", r.GetAuditLog)
+	}
+}
+
+// GetAuditLog retrieves audit log entries based on the provided filter.
+// @Summary Get audit log
+// @Tags Audit
+// @Produce json
+// @Param X-Admin-Token header string true "Admin token"
+// @Param filters query model.AuditFilter true "Filters"
+// @Success 200 {array} model.AuditEntry "List of audit entries"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 403 {object} ProblemDetails "Admin access required"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /audit [get]
+func (h *auditHandler) GetAuditLog(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start get audit log")
+	var filter model.AuditFilter
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		_ = c.Error(bindError(err))
+		return
+	}
+
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.PerPage == 0 {
+		filter.PerPage = 10
+	}
+	logger.Logger.DebugContext(ctx, "parsed filter", slog.Any("filter", filter))
+	entries, err := h.service.GetAuditLog(ctx, filter)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "got audit log")
+	c.JSON(http.StatusOK, entries)
+}
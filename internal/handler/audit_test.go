@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"net/http"
+	"testing"
+)
+
+func TestGetAuditLog(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(model.User{})
+	expectStatus(t, api.do(http.MethodDelete, "/api/user/1", "", actorHeader, "admin"), http.StatusOK)
+
+	expectProblem(t, api.do(http.MethodGet, "/api/audit/", ""), http.StatusForbidden, model.CodeForbidden)
+
+	w := api.do(http.MethodGet, "/api/audit/?actor=admin", "", adminTokenHeader, testAdminToken)
+	expectStatus(t, w, http.StatusOK)
+	entries := decode[[]model.AuditEntry](t, w)
+	if len(entries) != 1 || entries[0].Action != model.AuditActionUserDelete {
+		t.Fatalf("unexpected audit log %+v", entries)
+	}
+
+	problem := expectProblem(t, api.do(http.MethodGet, "/api/audit/?from=yesterday", "", adminTokenHeader, testAdminToken), http.StatusBadRequest, model.CodeValidationFailed)
+	if len(problem.Errors) != 1 {
+		t.Errorf("expected field error, got %+v", problem.Errors)
+	}
+	expectProblem(t, api.do(http.MethodGet, "/api/audit/?per_page=101", "", adminTokenHeader, testAdminToken), http.StatusBadRequest, model.CodeValidationFailed)
+}
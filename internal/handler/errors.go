@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const problemContentType = "application/problem+json"
+
+// problemStatuses maps domain error codes to HTTP statuses. Unknown codes are answered with 500.
+var problemStatuses = map[string]int{
+	model.CodeValidationFailed:    http.StatusBadRequest,
+	model.CodeForbidden:           http.StatusForbidden,
+	model.CodeUserNotFound:        http.StatusNotFound,
+	model.CodeUserVersionNotFound: http.StatusNotFound,
+	model.CodeTaskNotFound:        http.StatusNotFound,
+	model.CodeImportJobNotFound:   http.StatusNotFound,
+	model.CodeUserAlreadyExists:   http.StatusConflict,
+	model.CodeTaskAlreadyStarted:  http.StatusConflict,
+	model.CodeTaskAlreadyStopped:  http.StatusConflict,
+	model.CodeIdempotencyConflict: http.StatusConflict,
+	model.CodeIdempotencyMismatch: http.StatusUnprocessableEntity,
+	model.CodeExternalAPIFailed:   http.StatusBadGateway,
+	model.CodeImportQueueFull:     http.StatusServiceUnavailable,
+	model.CodeInternal:            http.StatusInternalServerError,
+}
+
+func init() {
+	// Report JSON and query names of fields in validation errors instead of Go struct field names.
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(f reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+				if name != "" && name != "-" {
+					return name
+				}
+			}
+			return f.Name
+		})
+	}
+}
+
+// errorMiddleware renders the last error attached to the context with c.Error as RFC 7807 problem details.
+// Handlers only attach errors and return; the HTTP status is chosen here from the domain error code.
+func errorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+		problem := newProblemDetails(c, err)
+
+		ctx := c.Request.Context()
+		attrs := []any{slog.String("code", problem.Code), slog.String("error", err.Error())}
+		if problem.Status >= http.StatusInternalServerError {
+			logger.Logger.ErrorContext(ctx, "request failed", attrs...)
+		} else {
+			logger.Logger.WarnContext(ctx, "request rejected", attrs...)
+		}
+
+		body, marshalErr := json.Marshal(problem)
+		if marshalErr != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(problem.Status, problemContentType, body)
+		c.Abort()
+	}
+}
+
+func newProblemDetails(c *gin.Context, err error) ProblemDetails {
+	var domainErr *model.Error
+	if !errors.As(err, &domainErr) {
+		domainErr = &model.Error{Code: model.CodeInternal, Message: "internal server error"}
+	}
+	status, ok := problemStatuses[domainErr.Code]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	detail := domainErr.Message
+	if status >= http.StatusInternalServerError && domainErr.Code == model.CodeInternal {
+		// Do not leak internals such as SQL errors to the client.
+		detail = "internal server error"
+	}
+
+	return ProblemDetails{
+		Type:       "about:blank",
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     detail,
+		Instance:   c.Request.URL.Path,
+		Code:       domainErr.Code,
+		RequestID:  logger.RequestID(c.Request.Context()),
+		Errors:     domainErr.Fields,
+		ExistingID: domainErr.ExistingID,
+	}
+}
+
+// bindError converts an error of c.Bind* into a validation error with per-field details.
+func bindError(err error) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]model.FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, model.FieldError{Field: fe.Field(), Message: validationMessage(fe)})
+		}
+		return model.NewValidationError(fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return model.NewValidationError(model.FieldError{
+			Field:   typeErr.Field,
+			Message: "must be of type " + typeErr.Type.String(),
+		})
+	}
+
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return model.NewValidationError(model.FieldError{Field: "body", Message: "malformed JSON"})
+	}
+
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		return model.NewValidationError(model.FieldError{Field: "query", Message: "invalid number " + strconv.Quote(numErr.Num)})
+	}
+
+	var timeErr *time.ParseError
+	if errors.As(err, &timeErr) {
+		return model.NewValidationError(model.FieldError{Field: "query", Message: "invalid date " + strconv.Quote(timeErr.Value)})
+	}
+
+	return model.NewValidationError(model.FieldError{Field: "body", Message: err.Error()})
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "oneof":
+		return "must be one of: " + fe.Param()
+	default:
+		return "failed on " + fe.Tag()
+	}
+}
+
+// fieldError returns a validation error for a single path or query parameter.
+func fieldError(field, message string) error {
+	return model.NewValidationError(model.FieldError{Field: field, Message: message})
+}
+
+// paramID parses a positive integer path parameter.
+func paramID(c *gin.Context, name string) (int, error) {
+	id, err := strconv.Atoi(c.Param(name))
+	if err != nil || id <= 0 {
+		return 0, fieldError(name, "must be a positive integer")
+	}
+	return id, nil
+}
+
+// queryTime parses a required query parameter in the "2006-01-02 15:04:05" layout.
+func queryTime(c *gin.Context, name string) (time.Time, error) {
+	value, ok := c.GetQuery(name)
+	if !ok || value == "" {
+		return time.Time{}, fieldError(name, "is required")
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", value)
+	if err != nil {
+		return time.Time{}, fieldError(name, "must be in format 2006-01-02 15:04:05")
+	}
+	return t, nil
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestErrorMiddleware(t *testing.T) {
+	newTestLogger(t)
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+		wantDetail string
+	}{
+		{"not found", model.ErrUserNotFound, http.StatusNotFound, model.CodeUserNotFound, "user not found"},
+		{"wrapped sentinel", fmt.Errorf("stop: %w", model.ErrTaskAlreadyStopped), http.StatusConflict, model.CodeTaskAlreadyStopped, "task already stopped"},
+		{"forbidden", model.ErrForbidden, http.StatusForbidden, model.CodeForbidden, "admin access required"},
+		{"external api", model.NewError(model.CodeExternalAPIFailed, "external api failed", errors.New("timeout")), http.StatusBadGateway, model.CodeExternalAPIFailed, "external api failed"},
+		{"unknown error is hidden", errors.New("pq: relation does not exist"), http.StatusInternalServerError, model.CodeInternal, "internal server error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(errorMiddleware())
+			router.GET("/", func(c *gin.Context) { _ = c.Error(tt.err) })
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != problemContentType {
+				t.Errorf("expected content type %q, got %q", problemContentType, ct)
+			}
+			var body ProblemDetails
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != tt.wantCode || body.Detail != tt.wantDetail || body.Status != tt.wantStatus {
+				t.Errorf("unexpected body %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestBindErrorFields(t *testing.T) {
+	newTestLogger(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(errorMiddleware())
+	router.POST("/", func(c *gin.Context) {
+		var input model.TaskRequestBody
+		if err := c.ShouldBindJSON(&input); err != nil {
+			_ = c.Error(bindError(err))
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"description":"x"}`)))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	var body ProblemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Code != model.CodeValidationFailed || len(body.Errors) != 2 {
+		t.Fatalf("expected two field errors, got %s", w.Body.String())
+	}
+	if body.Errors[0].Field != "user_id" || body.Errors[1].Field != "name" {
+		t.Errorf("expected JSON field names, got %+v", body.Errors)
+	}
+}
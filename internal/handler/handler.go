@@ -2,14 +2,44 @@ package handler
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/jmoiron/sqlx"
 	swaggerfiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	_ "github.com/usmonzodasomon/time-tracker/docs"
+	"github.com/usmonzodasomon/time-tracker/internal/config"
+	"github.com/usmonzodasomon/time-tracker/internal/external_api"
+	"github.com/usmonzodasomon/time-tracker/internal/health"
+	"github.com/usmonzodasomon/time-tracker/internal/metrics"
+	"github.com/usmonzodasomon/time-tracker/internal/service"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func NewRouter(handler *gin.Engine, db *sqlx.DB) {
+// Services are the dependencies of the HTTP layer. They are built by the caller,
+// so implementations can be swapped, e.g. for services over the memory repositories in tests.
+type Services struct {
+	User        service.UserServiceI
+	Task        service.TaskServiceI
+	Audit       service.AuditServiceI
+	Search      service.SearchServiceI
+	ImportJobs  service.ImportJobsI
+	Idempotency service.IdempotencyServiceI
+	ExternalAPI external_api.UserExternalInfoI
+	Health      *health.Checker
+}
+
+func NewRouter(handler *gin.Engine, services Services, cfg *config.Config) {
+	handler.Use(
+		otelgin.Middleware(cfg.Tracing.ServiceName),
+		requestIDMiddleware(),
+		accessLogMiddleware(),
+		metricsMiddleware(),
+		errorMiddleware(),
+		recoveryMiddleware(),
+		actorMiddleware(),
+	)
 	handler.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
+	handler.GET("/metrics", gin.WrapH(metrics.Handler()))
+	newHealthHandler(handler, services.Health)
+
 	h := handler.Group("/api")
 	{
 		h.GET("/ping", func(c *gin.Context) {
@@ -18,8 +48,11 @@ func NewRouter(handler *gin.Engine, db *sqlx.DB) {
 			})
 		})
 
-		newUserHandler(h, db)
-		newTaskHandler(h, db)
+		newUserHandler(h, services.User, services.ExternalAPI, services.Idempotency, cfg.AdminToken)
+		newTaskHandler(h, services.Task, services.User)
+		newAuditHandler(h, services.Audit, cfg.AdminToken)
+		newSearchHandler(h, services.Search)
+		newImportHandler(h, services.ImportJobs, cfg.Import.MaxRows)
 
 	}
 }
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/usmonzodasomon/time-tracker/internal/config"
+	"github.com/usmonzodasomon/time-tracker/internal/external_api/mocks"
+	"github.com/usmonzodasomon/time-tracker/internal/health"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/memory"
+	"github.com/usmonzodasomon/time-tracker/internal/service"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAdminToken = "secret"
+
+// testAPI serves the router with the real services over memory repositories of one store.
+type testAPI struct {
+	t      *testing.T
+	users  *memory.UserRepo
+	tasks  *memory.TaskRepo
+	audit  *memory.AuditRepo
+	router *gin.Engine
+
+	passports int
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	newTestLogger(t)
+	gin.SetMode(gin.TestMode)
+
+	store := memory.NewStore()
+	users, tasks, audit, tx := memory.NewUserRepo(store), memory.NewTaskRepo(store), memory.NewAuditRepo(store), memory.NewTxManager(store)
+	externalAPI := mocks.NewUserExternalInfo()
+	cfg := &config.Config{AdminToken: testAdminToken, Import: config.ImportConfig{MaxRows: 3}}
+	importJobs := service.NewImportJobs(service.NewImportService(users, externalAPI, tx, 2, 10), 1, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		importJobs.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	router := gin.New()
+	NewRouter(router, Services{
+		User:        service.NewUserService(users, tasks, tx),
+		Task:        service.NewTaskService(tasks, tx),
+		Audit:       service.NewAuditService(audit),
+		Search:      service.NewSearchService(users, tasks),
+		ImportJobs:  importJobs,
+		Idempotency: service.NewIdempotencyService(memory.NewIdempotencyRepo(store), time.Hour, time.Minute),
+		ExternalAPI: externalAPI,
+		Health:      health.NewChecker(time.Second),
+	}, cfg)
+	return &testAPI{t: t, users: users, tasks: tasks, audit: audit, router: router}
+}
+
+// createUser stores the user with a passport of its own unless one is set and returns its ID.
+func (a *testAPI) createUser(user model.User) int {
+	a.t.Helper()
+	if user.PassportSerie == 0 {
+		a.passports++
+		user.PassportSerie, user.PassportNumber = 1000, 1000+a.passports
+	}
+	id, err := a.users.CreateUser(context.Background(), user, model.AuditMeta{})
+	if err != nil {
+		a.t.Fatalf("CreateUser: %v", err)
+	}
+	return id
+}
+
+func (a *testAPI) deleteUser(id int) {
+	a.t.Helper()
+	if err := a.users.DeleteUser(context.Background(), id, model.AuditMeta{}); err != nil {
+		a.t.Fatalf("DeleteUser: %v", err)
+	}
+}
+
+func (a *testAPI) createTask(task model.Task) int {
+	a.t.Helper()
+	id, err := a.tasks.CreateTask(context.Background(), task, model.AuditMeta{})
+	if err != nil {
+		a.t.Fatalf("CreateTask: %v", err)
+	}
+	return id
+}
+
+// countUsers returns the number of active users.
+func (a *testAPI) countUsers() int {
+	a.t.Helper()
+	_, total, err := a.users.GetAllUsers(context.Background(), model.UserFilter{PageFilter: model.PageFilter{Page: 1, PerPage: 1}})
+	if err != nil {
+		a.t.Fatalf("GetAllUsers: %v", err)
+	}
+	return total
+}
+
+// do sends a request with an optional JSON body and headers given as name, value pairs.
+func (a *testAPI) do(method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("error decoding response %q: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+// expectProblem checks the status and the domain error code of a problem details response.
+func expectProblem(t *testing.T, w *httptest.ResponseRecorder, status int, code string) ProblemDetails {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+	problem := decode[ProblemDetails](t, w)
+	if problem.Code != code {
+		t.Fatalf("expected code %s, got %s", code, problem.Code)
+	}
+	return problem
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+}
+
+func TestPingAndHealth(t *testing.T) {
+	api := newTestAPI(t)
+
+	expectStatus(t, api.do(http.MethodGet, "/api/ping", ""), http.StatusOK)
+	expectStatus(t, api.do(http.MethodGet, "/healthz", ""), http.StatusOK)
+	expectStatus(t, api.do(http.MethodGet, "/readyz", ""), http.StatusOK)
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/usmonzodasomon/time-tracker/internal/health"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"log/slog"
+	"net/http"
+)
+
+type healthHandler struct {
+	checker *health.Checker
+}
+
+func newHealthHandler(handler *gin.Engine, checker *health.Checker) {
+	r := &healthHandler{checker: checker}
+
+	handler.GET("/healthz", r.Liveness)
+	handler.GET("/readyz", r.Readiness)
+}
+
+// Liveness reports that the process is running.
+func (h *healthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, health.Report{Status: health.StatusUp, Components: map[string]health.ComponentStatus{}})
+}
+
+// Readiness checks dependencies of the service and answers 503 if a required one is down.
+func (h *healthHandler) Readiness(c *gin.Context) {
+	ctx := c.Request.Context()
+	report := h.checker.Run(ctx)
+	if !report.Ready() {
+		logger.Logger.WarnContext(ctx, "service is not ready", slog.Any("report", report))
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
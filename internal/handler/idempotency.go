@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/usmonzodasomon/time-tracker/internal/service"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+// idempotent makes a route safe to retry: a request repeated with the same Idempotency-Key header
+// gets the response of the first one instead of being handled again. Only successful responses
+// are remembered, after an error the key is released and a retry is handled as a new request.
+// Requests without the header are handled as usual.
+func idempotent(idempotency service.IdempotencyServiceI) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			_ = c.Error(fieldError(idempotencyKeyHeader, fmt.Sprintf("must be at most %d characters", maxIdempotencyKeyLength)))
+			c.Abort()
+			return
+		}
+
+		ctx := c.Request.Context()
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIdempotentRequestBytes))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				err = fieldError("body", fmt.Sprintf("must be at most %d bytes", maxBytesErr.Limit))
+			}
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, err := idempotency.Begin(ctx, key, requestFingerprint(c.Request, body))
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		if record.Completed() {
+			logger.Logger.InfoContext(ctx, "replaying response of idempotent request", slog.Int("status", record.Status))
+			c.Header(idempotentReplayedHeader, "true")
+			c.Data(record.Status, gin.MIMEJSON+"; charset=utf-8", record.Body)
+			c.Abort()
+			return
+		}
+
+		// The outcome is stored even when the client has gone away, so that its retry sees it.
+		ctx = context.WithoutCancel(ctx)
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		completed := false
+		defer func() {
+			c.Writer = recorder.ResponseWriter
+			if completed {
+				return
+			}
+			if err := idempotency.Release(ctx, record); err != nil {
+				logger.Logger.ErrorContext(ctx, "error releasing idempotency key", slog.String("error", err.Error()))
+			}
+		}()
+
+		c.Next()
+
+		if status := recorder.Status(); recorder.Written() && status >= 200 && status < 300 {
+			if err := idempotency.Complete(ctx, record, status, recorder.body.Bytes()); err != nil {
+				logger.Logger.ErrorContext(ctx, "error storing idempotent response", slog.String("error", err.Error()))
+				return
+			}
+			completed = true
+		}
+	}
+}
+
+// requestFingerprint identifies the request, so that a key cannot be reused for a different one.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.Path)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body written through it.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/service"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+const (
+	// maxImportLineSize bounds the size of an import request together with the number of rows.
+	maxImportLineSize = 64
+	// importFormOverhead leaves room for the multipart form around the file.
+	importFormOverhead = 4 << 10
+)
+
+type importHandler struct {
+	jobs    service.ImportJobsI
+	maxRows int
+}
+
+func newImportHandler(handler *gin.RouterGroup, jobs service.ImportJobsI, maxRows int) {
+	r := &importHandler{
+		jobs:    jobs,
+		maxRows: maxRows,
+	}
+
+	handler.POST("/user/import", r.ImportUsers)
+	handler.GET("/user/import/:job_id", r.GetImportJob)
+}
+
+// ImportUsers queues creation of users for a CSV of passports. Resolving the passports may take
+// longer than a request is allowed to, so the job is returned right away and its report is polled.
+// The CSV is sent either as the request body or as the "file" field of a multipart form.
+// @Summary Import users
+// @Tags Users
+// @Accept text/csv
+// @Accept mpfd
+// @Produce json
+// @Param file formData file false "CSV with a passport per line, e.g. 1234 567890"
+// @Param X-Actor header string false "Actor recorded in the audit log"
+// @Success 202 {object} model.ImportJob "Queued import, polled at the Location header"
+// @Failure 400 {object} ProblemDetails "Malformed or too large file"
+// @Failure 503 {object} ProblemDetails "Too many imports are waiting"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/import [post]
+func (h *importHandler) ImportUsers(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start import users")
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.maxRows)*maxImportLineSize+importFormOverhead)
+
+	file, err := h.openFile(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	defer file.Close()
+
+	rows, err := model.ParseImportCSV(file)
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		_ = c.Error(fieldError("file", fmt.Sprintf("must be at most %d bytes", maxBytesErr.Limit)))
+		return
+	case err != nil:
+		_ = c.Error(fieldError("file", "is not a valid CSV: "+err.Error()))
+		return
+	case len(rows) == 0:
+		_ = c.Error(fieldError("file", "has no passports"))
+		return
+	case len(rows) > h.maxRows:
+		_ = c.Error(fieldError("file", fmt.Sprintf("must have at most %d rows", h.maxRows)))
+		return
+	}
+	logger.Logger.DebugContext(ctx, "parsed import file", slog.Int("rows", len(rows)))
+
+	job, err := h.jobs.StartImport(ctx, rows, auditMeta(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header("Location", c.Request.URL.Path+"/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetImportJob returns the status of an import and, once it is done, the result of every row.
+// @Summary Get import job
+// @Tags Users
+// @Produce json
+// @Param job_id path string true "Import job ID"
+// @Success 200 {object} model.ImportJob "Import job"
+// @Failure 404 {object} ProblemDetails "Job not found or expired"
+// @Router /user/import/{job_id} [get]
+func (h *importHandler) GetImportJob(c *gin.Context) {
+	job, err := h.jobs.GetImportJob(c.Request.Context(), c.Param("job_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// openFile returns the uploaded file of a multipart form or the request body otherwise.
+func (h *importHandler) openFile(c *gin.Context) (io.ReadCloser, error) {
+	if c.ContentType() != gin.MIMEMultipartPOSTForm {
+		return c.Request.Body, nil
+	}
+	header, err := c.FormFile("file")
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return nil, fieldError("file", fmt.Sprintf("must be at most %d bytes", maxBytesErr.Limit))
+	case err != nil:
+		return nil, fieldError("file", "is required")
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening uploaded file: %w", err)
+	}
+	return file, nil
+}
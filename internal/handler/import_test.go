@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"bytes"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportUsers(t *testing.T) {
+	api := newTestAPI(t)
+
+	w := api.do(http.MethodPost, "/api/user/import", "passport\n1234 5678\n1234,5678\n12345678\n", "Content-Type", "text/csv")
+	expectStatus(t, w, http.StatusAccepted)
+	job := decode[model.ImportJob](t, w)
+	if job.Status != model.ImportJobQueued || job.Rows != 3 || w.Header().Get("Location") != "/api/user/import/"+job.ID {
+		t.Fatalf("unexpected job %+v at %q", job, w.Header().Get("Location"))
+	}
+	report := api.waitImport(job.ID)
+	if report.Created != 1 || report.Duplicate != 1 || report.Invalid != 1 || len(report.Results) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if r := report.Results[0]; r.Line != 2 || r.Status != model.ImportStatusCreated || r.UserID != 1 {
+		t.Errorf("unexpected first row %+v", r)
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "team.csv")
+	_, _ = part.Write([]byte("4321 8765\n"))
+	_ = form.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/user/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w = httptest.NewRecorder()
+	api.router.ServeHTTP(w, req)
+	expectStatus(t, w, http.StatusAccepted)
+	if report := api.waitImport(decode[model.ImportJob](t, w).ID); report.Created != 1 {
+		t.Fatalf("unexpected report of uploaded file %+v", report)
+	}
+
+	expectProblem(t, api.do(http.MethodGet, "/api/user/import/unknown", ""), http.StatusNotFound, model.CodeImportJobNotFound)
+
+	for name, csv := range map[string]string{
+		"empty":     "passport\n",
+		"too long":  "1 1\n2 2\n3 3\n4 4\n",
+		"malformed": "\"1234 5678\n",
+		"too large": strings.Repeat("1", 5000),
+	} {
+		t.Run(name, func(t *testing.T) {
+			problem := expectProblem(t, api.do(http.MethodPost, "/api/user/import", csv, "Content-Type", "text/csv"), http.StatusBadRequest, model.CodeValidationFailed)
+			if len(problem.Errors) != 1 || problem.Errors[0].Field != "file" {
+				t.Errorf("expected error for field file, got %+v", problem.Errors)
+			}
+		})
+	}
+}
+
+// waitImport polls the import job until it is done and returns its report.
+func (a *testAPI) waitImport(id string) model.ImportReport {
+	a.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		w := a.do(http.MethodGet, "/api/user/import/"+id, "")
+		expectStatus(a.t, w, http.StatusOK)
+		job := decode[model.ImportJob](a.t, w)
+		switch job.Status {
+		case model.ImportJobDone:
+			return *job.Report
+		case model.ImportJobFailed:
+			a.t.Fatalf("import failed: %+v", job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	a.t.Fatalf("import %s is not done", id)
+	return model.ImportReport{}
+}
@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/usmonzodasomon/time-tracker/internal/metrics"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	adminTokenHeader = "X-Admin-Token"
+	actorHeader      = "X-Actor"
+	requestIDHeader  = "X-Request-ID"
+
+	anonymousActor = "anonymous"
+	// maxActorLength is the size of the columns the actor is stored in.
+	maxActorLength = 255
+	// maxRequestIDLength bounds request IDs taken from clients.
+	maxRequestIDLength = 64
+)
+
+// adminOnly rejects requests that do not carry the configured admin token.
+// When no token is configured, admin routes are disabled entirely.
+func adminOnly(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdmin(c, token) {
+			_ = c.Error(model.ErrForbidden)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// isAdmin reports whether the request carries the configured admin token.
+func isAdmin(c *gin.Context, token string) bool {
+	provided := c.GetHeader(adminTokenHeader)
+	return token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// getActor returns the identity of the caller passed in the X-Actor header.
+func getActor(c *gin.Context) string {
+	return c.GetHeader(actorHeader)
+}
+
+// actorMiddleware rejects requests whose X-Actor header does not fit into the audit log,
+// so that mutations do not fail halfway with a database error.
+func actorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if utf8.RuneCountInString(getActor(c)) > maxActorLength {
+			_ = c.Error(fieldError(actorHeader, fmt.Sprintf("must be at most %d characters", maxActorLength)))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// auditMeta describes the caller of the request for the audit log.
+func auditMeta(c *gin.Context) model.AuditMeta {
+	actor := getActor(c)
+	if actor == "" {
+		actor = anonymousActor
+	}
+	return model.AuditMeta{
+		Actor:     actor,
+		RequestID: logger.RequestID(c.Request.Context()),
+	}
+}
+
+// metricsMiddleware records count and latency of requests per route template.
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// requestIDMiddleware reuses the X-Request-ID header of the request or generates a new ID
+// when the header is missing or invalid, returns it in the response and attaches it
+// to the request context for logging.
+func requestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(requestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+// validRequestID reports whether id is non-empty, at most maxRequestIDLength long
+// and consists of letters, digits, dots, underscores and dashes only.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// accessLogMiddleware writes one log line per request once it is handled.
+func accessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		logger.Logger.LogAttrs(c.Request.Context(), level, "http request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+			slog.String("user_agent", c.Request.UserAgent()),
+		)
+	}
+}
+
+// recoveryMiddleware turns panics in handlers into an internal error rendered by errorMiddleware.
+func recoveryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.Logger.ErrorContext(c.Request.Context(), "panic recovered",
+					slog.Any("panic", r),
+					slog.String("stack", string(debug.Stack())))
+				_ = c.Error(model.NewError(model.CodeInternal, "internal server error", fmt.Errorf("panic: %v", r)))
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newTestLogger(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := logger.Logger
+	logger.Logger = slog.New(logger.NewContextHandler(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { logger.Logger = prev })
+	return &buf
+}
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(requestIDMiddleware(), accessLogMiddleware(), errorMiddleware(), recoveryMiddleware())
+	router.GET("/ok", func(c *gin.Context) {
+		logger.Logger.InfoContext(c.Request.Context(), "inside handler")
+		c.JSON(http.StatusOK, newSuccessResponse("ok"))
+	})
+	router.GET("/panic", func(c *gin.Context) {
+		panic("boom")
+	})
+	return router
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	buf := newTestLogger(t)
+	router := newTestRouter()
+
+	req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+	req.Header.Set(requestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got := w.Header().Get(requestIDHeader); got != "req-123" {
+		t.Errorf("expected request id to be propagated, got %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected handler and access log lines, got %d: %s", len(lines), buf.String())
+	}
+	for _, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatal(err)
+		}
+		if record["request_id"] != "req-123" {
+			t.Errorf("expected request_id in log line %s", line)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	if w.Header().Get(requestIDHeader) == "" {
+		t.Error("expected request id to be generated")
+	}
+
+	for _, invalid := range []string{strings.Repeat("a", maxRequestIDLength+1), "req 1", "req\u00e91", "<script>"} {
+		req := httptest.NewRequest(http.MethodGet, "/ok", nil)
+		req.Header.Set(requestIDHeader, invalid)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if got := w.Header().Get(requestIDHeader); got == invalid || !validRequestID(got) {
+			t.Errorf("expected a new request id instead of %q, got %q", invalid, got)
+		}
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	api := newTestAPI(t)
+	series := `timetracker_http_requests_total{method="GET",route="/api/ping",status="200"} `
+
+	expectStatus(t, api.do(http.MethodGet, "/api/ping", ""), http.StatusOK)
+	before := scrapeMetric(t, api, series)
+	expectStatus(t, api.do(http.MethodGet, "/api/ping", ""), http.StatusOK)
+	if after := scrapeMetric(t, api, series); after != before+1 {
+		t.Errorf("expected %s to grow by 1, got %v after %v", series, after, before)
+	}
+
+	expectStatus(t, api.do(http.MethodGet, "/api/no-such-route", ""), http.StatusNotFound)
+	if scrapeMetric(t, api, `timetracker_http_requests_total{method="GET",route="unmatched",status="404"} `) == 0 {
+		t.Error("expected unmatched requests to be counted without their path")
+	}
+}
+
+// scrapeMetric returns the value of the series, given as its name and labels, from GET /metrics.
+func scrapeMetric(t *testing.T, api *testAPI, series string) float64 {
+	t.Helper()
+	w := api.do(http.MethodGet, "/metrics", "")
+	expectStatus(t, w, http.StatusOK)
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if value, ok := strings.CutPrefix(line, series); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatalf("error parsing %q: %v", line, err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("series %s not found in:\n%s", series, w.Body.String())
+	return 0
+}
+
+func TestActorMiddleware(t *testing.T) {
+	api := newTestAPI(t)
+
+	w := api.do(http.MethodPost, "/api/user/", `{"passportNumber": "1234 5678"}`, actorHeader, strings.Repeat("a", maxActorLength+1))
+	problem := expectProblem(t, w, http.StatusBadRequest, model.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != actorHeader {
+		t.Errorf("expected error on %s, got %+v", actorHeader, problem.Errors)
+	}
+	if api.countUsers() != 0 {
+		t.Error("user must not be created")
+	}
+
+	w = api.do(http.MethodPost, "/api/user/", `{"passportNumber": "1234 5678"}`, actorHeader, strings.Repeat("я", maxActorLength))
+	expectStatus(t, w, http.StatusCreated)
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+	buf := newTestLogger(t)
+	router := newTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+	var body ProblemDetails
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Code != model.CodeInternal {
+		t.Errorf("expected problem details body, got %s", w.Body.String())
+	}
+	if !strings.Contains(buf.String(), `"status":500`) {
+		t.Errorf("expected access log with status 500, got %s", buf.String())
+	}
+}
@@ -1,17 +1,88 @@
 package handler
 
+import "github.com/usmonzodasomon/time-tracker/internal/model"
+
 type SuccessResponse struct {
 	Message string `json:"message"`
 }
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// ProblemDetails is an RFC 7807 error response extended with a domain error code,
+// the request ID, field-level validation details and, for conflicts, the ID of the existing resource.
+type ProblemDetails struct {
+	Type       string             `json:"type" example:"about:blank"`
+	Title      string             `json:"title" example:"Not Found"`
+	Status     int                `json:"status" example:"404"`
+	Detail     string             `json:"detail" example:"user not found"`
+	Instance   string             `json:"instance" example:"/api/user/1/history"`
+	Code       string             `json:"code" example:"USER_NOT_FOUND"`
+	RequestID  string             `json:"request_id,omitempty"`
+	Errors     []model.FieldError `json:"errors,omitempty"`
+	ExistingID int                `json:"existing_id,omitempty" example:"1"`
 }
 
-func newSuccessResponse(message string) SuccessResponse {
-	return SuccessResponse{Message: message}
+// PageMeta describes the position of a page in the whole result.
+// Page is omitted for pages requested by cursor.
+type PageMeta struct {
+	Page       int    `json:"page,omitempty" example:"1"`
+	PerPage    int    `json:"per_page" example:"10"`
+	Total      int    `json:"total" example:"42"`
+	TotalPages int    `json:"total_pages" example:"5"`
+	NextCursor string `json:"next_cursor,omitempty" example:"eyJrIjpbIjExIl19"`
+	PrevCursor string `json:"prev_cursor,omitempty" example:"eyJrIjpbIjIiXSwiYiI6dHJ1ZX0"`
+}
+
+type UserListResponse struct {
+	Data []model.User `json:"data"`
+	Meta PageMeta     `json:"meta"`
+}
+
+type TaskListResponse struct {
+	Data []model.Task `json:"data"`
+	Meta PageMeta     `json:"meta"`
 }
 
-func newErrorResponse(err string) ErrorResponse {
-	return ErrorResponse{Error: err}
+type TimeEntryListResponse struct {
+	Data []model.TimeEntry `json:"data"`
+	Meta PageMeta          `json:"meta"`
+}
+
+func newPageMeta[T any](filter model.PageFilter, page model.Page[T]) PageMeta {
+	meta := PageMeta{
+		PerPage:    filter.PerPage,
+		Total:      page.Total,
+		TotalPages: (page.Total + filter.PerPage - 1) / filter.PerPage,
+		NextCursor: page.NextCursor,
+		PrevCursor: page.PrevCursor,
+	}
+	if filter.After == nil {
+		meta.Page = filter.Page
+	}
+	return meta
+}
+
+// bindPage fills in the defaults of a page filter and decodes its cursor,
+// which must have been made for the same sort parameter.
+func bindPage(filter *model.PageFilter, sort string) error {
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.PerPage == 0 {
+		filter.PerPage = 10
+	}
+	if filter.Cursor == "" {
+		return nil
+	}
+	cursor, err := model.DecodeCursor(filter.Cursor)
+	if err != nil {
+		return err
+	}
+	if cursor.Sort != sort {
+		return model.ErrInvalidCursor
+	}
+	filter.After = cursor
+	return nil
+}
+
+func newSuccessResponse(message string) SuccessResponse {
+	return SuccessResponse{Message: message}
 }
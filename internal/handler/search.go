@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/service"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type searchHandler struct {
+	service service.SearchServiceI
+}
+
+func newSearchHandler(handler *gin.RouterGroup, searchService service.SearchServiceI) {
+	r := &searchHandler{
+		service: searchService,
+	}
+
+	handler.GET("/search", r.Search)
+}
+
+// Search finds users and tasks by names, addresses and descriptions, the most relevant first.
+// @Summary Search users and tasks
+// @Tags Search
+// @Produce json
+// @Param filters query model.SearchFilter true "Query"
+// @Success 200 {array} model.SearchResult "Results ordered by relevance"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /search [get]
+func (h *searchHandler) Search(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start search")
+	var filter model.SearchFilter
+
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		_ = c.Error(bindError(err))
+		return
+	}
+	filter.Q = strings.TrimSpace(filter.Q)
+	if filter.Q == "" {
+		_ = c.Error(fieldError("q", "is required"))
+		return
+	}
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	logger.Logger.DebugContext(ctx, "parsed filter", slog.Any("filter", filter))
+	results, err := h.service.Search(ctx, filter)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "search done", slog.Int("results", len(results)))
+	if results == nil {
+		results = []model.SearchResult{}
+	}
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"net/http"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	api := newTestAPI(t)
+	api.createTask(model.Task{UserID: api.createUser(model.User{Name: "Петр"}), Name: "Отчет Петра"})
+	api.deleteUser(api.createUser(model.User{Name: "Петр"}))
+
+	w := api.do(http.MethodGet, "/api/search?q=Пет", "")
+	expectStatus(t, w, http.StatusOK)
+	results := decode[[]model.SearchResult](t, w)
+	if len(results) != 2 || results[0].Type != model.SearchTypeTask || results[0].Task.ID != 1 ||
+		results[1].Type != model.SearchTypeUser || results[1].User.ID != 1 {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	w = api.do(http.MethodGet, "/api/search?q=Пет&limit=1", "")
+	expectStatus(t, w, http.StatusOK)
+	if results := decode[[]model.SearchResult](t, w); len(results) != 1 {
+		t.Fatalf("expected limit to be applied, got %+v", results)
+	}
+
+	w = api.do(http.MethodGet, "/api/search?q=Сидор", "")
+	expectStatus(t, w, http.StatusOK)
+	if body := w.Body.String(); body != "[]" {
+		t.Errorf("expected empty array, got %s", body)
+	}
+
+	for _, query := range []string{"", "q=%20%20", "q=Петр&limit=101"} {
+		problem := expectProblem(t, api.do(http.MethodGet, "/api/search?"+query, ""), http.StatusBadRequest, model.CodeValidationFailed)
+		if len(problem.Errors) != 1 {
+			t.Errorf("expected a field error for %q, got %+v", query, problem.Errors)
+		}
+	}
+}
@@ -1,12 +1,9 @@
 package handler
 
 import (
-	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/jmoiron/sqlx"
 	"github.com/usmonzodasomon/time-tracker/internal/model"
-	"github.com/usmonzodasomon/time-tracker/internal/repository"
 	"github.com/usmonzodasomon/time-tracker/internal/service"
 	"github.com/usmonzodasomon/time-tracker/pkg/logger"
 	"log/slog"
@@ -19,13 +16,7 @@ type taskHandler struct {
 	userService service.UserServiceI
 }
 
-func newTaskHandler(handler *gin.RouterGroup, db *sqlx.DB) {
-	taskRepo := repository.NewTaskRepo(db)
-	userRepo := repository.NewUserRepo(db)
-
-	taskService := service.NewTaskService(taskRepo)
-	userService := service.NewUserService(userRepo)
-
+func newTaskHandler(handler *gin.RouterGroup, taskService service.TaskServiceI, userService service.UserServiceI) {
 	r := &taskHandler{
 		service:     taskService,
 		userService: userService,
@@ -47,45 +38,39 @@ func newTaskHandler(handler *gin.RouterGroup, db *sqlx.DB) {
 // @Produce json
 // @Param request body model.TaskRequestBody true "Task details"
 // @Success 201 {object} SuccessResponse  "Task ID"
-// @Failure 400 {object} ErrorResponse "Error message"
-// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "User not found"
+// @Failure 500 {object} ProblemDetails "Internal error"
 // @Router /task [post]
 func (h *taskHandler) CreateTask(c *gin.Context) {
-	logger.Logger.Info("start create task")
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start create task")
 	var input model.TaskRequestBody
 
-	if err := c.BindJSON(&input); err != nil {
-		logger.Logger.Error("error binding json", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error binding json"))
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(bindError(err))
 		return
 	}
 
-	logger.Logger.Debug("parsed input", slog.Any("input", input))
-	_, err := h.userService.GetUser(input.UserID)
+	logger.Logger.DebugContext(ctx, "parsed input", slog.Any("input", input))
+	_, err := h.userService.GetUser(ctx, input.UserID)
 	if err != nil {
-		if errors.Is(err, model.ErrUserNotFound) {
-			logger.Logger.Warn("user not found", slog.Int("user_id", input.UserID))
-			c.JSON(http.StatusBadRequest, newErrorResponse("user not found"))
-			return
-		}
-		logger.Logger.Error("error getting user", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error getting user"))
+		_ = c.Error(err)
 		return
 	}
 
-	taskID, err := h.service.CreateTask(model.Task{
+	taskID, err := h.service.CreateTask(ctx, model.Task{
 		UserID:      input.UserID,
 		Name:        input.Name,
 		Description: input.Description,
-	})
+	}, auditMeta(c))
 	if err != nil {
-		logger.Logger.Error("error creating task", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error creating task"))
+		_ = c.Error(err)
 		return
 	}
 
-	logger.Logger.Info("task created")
-	logger.Logger.Debug(fmt.Sprintf("task with id %d created", taskID))
+	logger.Logger.InfoContext(ctx, "task created")
+	logger.Logger.DebugContext(ctx, fmt.Sprintf("task with id %d created", taskID))
 	c.JSON(http.StatusCreated, newSuccessResponse(strconv.Itoa(taskID)))
 }
 
@@ -95,44 +80,34 @@ func (h *taskHandler) CreateTask(c *gin.Context) {
 // @Produce json
 // @Param task_id path int true "Task ID"
 // @Success 200 {object} SuccessResponse "Status of the task"
-// @Failure 400 {object} ErrorResponse "Error message"
-// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "Task not found"
+// @Failure 409 {object} ProblemDetails "Task already started"
+// @Failure 500 {object} ProblemDetails "Internal error"
 // @Router /task/{task_id}/start [post]
 func (h *taskHandler) StartTask(c *gin.Context) {
-	logger.Logger.Info("start task")
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start task")
 	taskID, err := h.getTaskID(c)
 	if err != nil {
-		logger.Logger.Error("error getting task id from param", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error getting task id from param"))
+		_ = c.Error(err)
 		return
 	}
 
-	_, err = h.service.GetTask(taskID)
+	_, err = h.service.GetTask(ctx, taskID)
 	if err != nil {
-		if errors.Is(err, model.ErrTaskNotFound) {
-			logger.Logger.Warn("task not found", slog.Int("task_id", taskID))
-			c.JSON(http.StatusBadRequest, newErrorResponse("task not found"))
-			return
-		}
-		logger.Logger.Error("error getting task", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error getting task"))
+		_ = c.Error(err)
 		return
 	}
 
-	err = h.service.StartTask(taskID)
+	err = h.service.StartTask(ctx, taskID, auditMeta(c))
 	if err != nil {
-		if errors.Is(err, model.ErrTaskAlreadyStarted) {
-			logger.Logger.Warn("task already started", slog.Int("task_id", taskID))
-			c.JSON(http.StatusBadRequest, newErrorResponse("task already started"))
-			return
-		}
-		logger.Logger.Error("error starting task", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error starting task"))
+		_ = c.Error(err)
 		return
 	}
 
-	logger.Logger.Info("task started")
-	logger.Logger.Debug(fmt.Sprintf("task with id %d started", taskID))
+	logger.Logger.InfoContext(ctx, "task started")
+	logger.Logger.DebugContext(ctx, fmt.Sprintf("task with id %d started", taskID))
 	c.JSON(http.StatusOK, newSuccessResponse("task started"))
 }
 
@@ -142,52 +117,37 @@ func (h *taskHandler) StartTask(c *gin.Context) {
 // @Produce json
 // @Param task_id path int true "Task ID"
 // @Success 200 {object} SuccessResponse "Status of the task"
-// @Failure 400 {object} ErrorResponse "Error message"
-// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "Task not found"
+// @Failure 409 {object} ProblemDetails "Task already stopped"
+// @Failure 500 {object} ProblemDetails "Internal error"
 // @Router /task/{task_id}/stop [post]
 func (h *taskHandler) StopTask(c *gin.Context) {
-	logger.Logger.Info("stop task")
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "stop task")
 	taskID, err := h.getTaskID(c)
 	if err != nil {
-		logger.Logger.Error("error getting task id from param", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error getting task id from param"))
+		_ = c.Error(err)
 		return
 	}
 
-	_, err = h.service.GetTask(taskID)
+	_, err = h.service.GetTask(ctx, taskID)
 	if err != nil {
-		if errors.Is(err, model.ErrTaskNotFound) {
-			logger.Logger.Warn("task not found", slog.Int("task_id", taskID))
-			c.JSON(http.StatusBadRequest, newErrorResponse("task not found"))
-			return
-		}
-		logger.Logger.Error("error getting task", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error getting task"))
+		_ = c.Error(err)
 		return
 	}
 
-	err = h.service.StopTask(taskID)
+	err = h.service.StopTask(ctx, taskID, auditMeta(c))
 	if err != nil {
-		if errors.Is(err, model.ErrTaskAlreadyStopped) {
-			logger.Logger.Warn("task already stopped", slog.Int("task_id", taskID))
-			c.JSON(http.StatusBadRequest, newErrorResponse("task already stopped"))
-			return
-		}
-		logger.Logger.Error("error stopping task", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		_ = c.Error(err)
 		return
 	}
 
-	logger.Logger.Info("task stopped")
-	logger.Logger.Debug(fmt.Sprintf("task with id %d stopped", taskID))
-	c.JSON(http.StatusOK, gin.H{"status": "task stopped"})
+	logger.Logger.InfoContext(ctx, "task stopped")
+	logger.Logger.DebugContext(ctx, fmt.Sprintf("task with id %d stopped", taskID))
+	c.JSON(http.StatusOK, newSuccessResponse("task stopped"))
 }
 
 func (h *taskHandler) getTaskID(c *gin.Context) (int, error) {
-	id := c.Param("task_id")
-	taskID, err := strconv.Atoi(id)
-	if err != nil {
-		return 0, err
-	}
-	return taskID, nil
+	return paramID(c, "task_id")
 }
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"net/http"
+	"testing"
+)
+
+func TestCreateTask(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(model.User{})
+
+	w := api.do(http.MethodPost, "/api/task/", `{"user_id": 1, "name": "report"}`)
+	expectStatus(t, w, http.StatusCreated)
+	if task, err := api.tasks.GetTask(context.Background(), 1); err != nil || task.UserID != 1 || task.Name != "report" {
+		t.Fatalf("unexpected task %+v, %v", task, err)
+	}
+
+	expectProblem(t, api.do(http.MethodPost, "/api/task/", `{"user_id": 2, "name": "report"}`), http.StatusNotFound, model.CodeUserNotFound)
+
+	problem := expectProblem(t, api.do(http.MethodPost, "/api/task/", `{"user_id": "one"}`), http.StatusBadRequest, model.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "user_id" {
+		t.Errorf("expected user_id type error, got %+v", problem.Errors)
+	}
+}
+
+func TestStartAndStopTask(t *testing.T) {
+	api := newTestAPI(t)
+	api.createTask(model.Task{UserID: api.createUser(model.User{})})
+
+	expectStatus(t, api.do(http.MethodPost, "/api/task/1/start", ""), http.StatusOK)
+	expectProblem(t, api.do(http.MethodPost, "/api/task/1/start", ""), http.StatusConflict, model.CodeTaskAlreadyStarted)
+
+	w := api.do(http.MethodPost, "/api/task/1/stop", "")
+	expectStatus(t, w, http.StatusOK)
+	if got := decode[SuccessResponse](t, w).Message; got != "task stopped" {
+		t.Errorf("unexpected message %q", got)
+	}
+	expectProblem(t, api.do(http.MethodPost, "/api/task/1/stop", ""), http.StatusConflict, model.CodeTaskAlreadyStopped)
+
+	expectProblem(t, api.do(http.MethodPost, "/api/task/2/start", ""), http.StatusNotFound, model.CodeTaskNotFound)
+	expectProblem(t, api.do(http.MethodPost, "/api/task/0/start", ""), http.StatusBadRequest, model.CodeValidationFailed)
+}
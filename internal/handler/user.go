@@ -4,81 +4,227 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"github.com/jmoiron/sqlx"
 	"github.com/usmonzodasomon/time-tracker/internal/external_api"
-	"github.com/usmonzodasomon/time-tracker/internal/external_api/mocks"
 	"github.com/usmonzodasomon/time-tracker/internal/model"
-	"github.com/usmonzodasomon/time-tracker/internal/repository"
 	"github.com/usmonzodasomon/time-tracker/internal/service"
 	"github.com/usmonzodasomon/time-tracker/pkg/logger"
 	"log/slog"
 	"net/http"
 	"strconv"
-	"strings"
-	"time"
 )
 
 type userHandler struct {
 	service         service.UserServiceI
 	externalApiInfo external_api.UserExternalInfoI
+	adminToken      string
 }
 
-func newUserHandler(handler *gin.RouterGroup, db *sqlx.DB) {
-	userRepo := repository.NewUserRepo(db)
-	userService := service.NewUserService(userRepo)
-	externalApiInfo := mocks.NewUserExternalInfo()
-
+func newUserHandler(handler *gin.RouterGroup, userService service.UserServiceI, externalApiInfo external_api.UserExternalInfoI,
+	idempotency service.IdempotencyServiceI, adminToken string) {
 	r := &userHandler{
 		service:         userService,
 		externalApiInfo: externalApiInfo,
+		adminToken:      adminToken,
 	}
 
 	h := handler.Group("/user")
 	{
 		h.GET("/", r.GetAllUsers)
+		h.GET("/:user_id", r.GetUser)
 		h.GET("/:user_id/time-spent", r.GetUserTimeSpent)
-		h.POST("/", r.CreateUser)
+		h.GET("/:user_id/tasks", r.GetUserTasks)
+		h.GET("/:user_id/time-entries", r.GetUserTimeEntries)
+		h.GET("/:user_id/history", r.GetUserHistory)
+		h.GET("/:user_id/snapshot", r.GetUserAsOf)
+		h.POST("/", idempotent(idempotency), r.CreateUser)
 		h.PATCH("/:user_id", r.UpdateUser)
 		h.DELETE("/:user_id", r.DeleteUser)
+		h.POST("/:user_id/restore", r.RestoreUser)
+		h.DELETE("/:user_id/permanent", adminOnly(adminToken), r.HardDeleteUser)
+		h.GET("/:user_id/export", adminOnly(adminToken), r.ExportUser)
+		h.POST("/:user_id/erase", adminOnly(adminToken), r.EraseUser)
 	}
 }
 
-// GetAllUsers retrieves all users based on the provided filter.
+// GetAllUsers retrieves a page of users based on the provided filter.
+// Deleted users are listed only for admins with include_deleted=true.
 // @Summary Get all users
 // @Tags Users
 // @Produce json
 // @Param filters query model.UserFilter true "Filters"
-// @Success 200 {array} model.User "List of users"
-// @Failure 400 {object} ErrorResponse "Error message"
-// @Failure 500 {object} ErrorResponse "Error message"
+// @Param X-Admin-Token header string false "Admin token, required for include_deleted"
+// @Success 200 {object} UserListResponse "Page of users"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 403 {object} ProblemDetails "include_deleted without admin token"
+// @Failure 500 {object} ProblemDetails "Internal error"
 // @Router /user [get]
 func (h *userHandler) GetAllUsers(c *gin.Context) {
-	logger.Logger.Info("start get all users")
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start get all users")
 	var filter model.UserFilter
 
-	if err := c.BindQuery(&filter); err != nil {
-		logger.Logger.Error("error binding query", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error binding query"))
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		_ = c.Error(bindError(err))
+		return
+	}
+
+	sortBy, err := model.ParseSort(filter.Sort, model.UserSortFields)
+	if err != nil {
+		_ = c.Error(fieldError("sort", err.Error()))
+		return
+	}
+	filter.SortBy = sortBy
+	if err := bindPage(&filter.PageFilter, filter.Sort); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	if filter.IncludeDeleted && !isAdmin(c, h.adminToken) {
+		_ = c.Error(model.ErrForbidden)
+		return
+	}
+
+	logger.Logger.DebugContext(ctx, "parsed filter", slog.Any("filter", filter))
+	page, err := h.service.GetAllUsers(ctx, filter)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "got users")
+	logger.Logger.DebugContext(ctx, "got users", slog.Any("users", page.Items))
+	if page.Items == nil {
+		page.Items = []model.User{}
+	}
+	c.JSON(http.StatusOK, UserListResponse{
+		Data: page.Items,
+		Meta: newPageMeta(filter.PageFilter, page),
+	})
+}
+
+// GetUser retrieves the user. Expansions listed in include are returned in the same response,
+// so that a dashboard needs a single call: tasks, running timers and lifetime and this week totals.
+// @Summary Get a user
+// @Tags Users
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param include query string false "Comma separated expansions: tasks, running, totals" example(tasks,running,totals)
+// @Success 200 {object} model.UserDetails "User with the requested expansions"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "User not found"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/{user_id} [get]
+func (h *userHandler) GetUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start get user")
+	userID, err := paramID(c, "user_id")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	include, err := model.ParseUserInclude(c.Query("include"))
+	if err != nil {
+		_ = c.Error(fieldError("include", err.Error()))
+		return
+	}
+
+	logger.Logger.DebugContext(ctx, "parsed", slog.Int("user_id", userID), slog.Any("include", include))
+	details, err := h.service.GetUserDetails(ctx, userID, include)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "got user")
+	c.JSON(http.StatusOK, details)
+}
+
+// GetUserTasks retrieves a page of the user's tasks ordered by ID.
+// @Summary Get user tasks
+// @Tags Users
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param page query model.PageFilter false "Page"
+// @Success 200 {object} TaskListResponse "Page of tasks"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "User not found"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/{user_id}/tasks [get]
+func (h *userHandler) GetUserTasks(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start get user tasks")
+	userID, filter, err := bindUserPage(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	page, err := h.service.GetUserTasks(ctx, userID, filter)
+	if err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	if filter.Page == 0 {
-		filter.Page = 1
+	logger.Logger.InfoContext(ctx, "got user tasks")
+	if page.Items == nil {
+		page.Items = []model.Task{}
 	}
-	if filter.PerPage == 0 {
-		filter.PerPage = 10
+	c.JSON(http.StatusOK, TaskListResponse{
+		Data: page.Items,
+		Meta: newPageMeta(filter, page),
+	})
+}
+
+// GetUserTimeEntries retrieves a page of the time entries of the user's tasks ordered by start time.
+// @Summary Get user time entries
+// @Tags Users
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param page query model.PageFilter false "Page"
+// @Success 200 {object} TimeEntryListResponse "Page of time entries"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "User not found"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/{user_id}/time-entries [get]
+func (h *userHandler) GetUserTimeEntries(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start get user time entries")
+	userID, filter, err := bindUserPage(c)
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
-	logger.Logger.Debug("parsed filter", slog.Any("filter", filter))
-	users, err := h.service.GetAllUsers(filter)
+
+	page, err := h.service.GetUserTimeEntries(ctx, userID, filter)
 	if err != nil {
-		logger.Logger.Error("error getting users", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error getting users"))
+		_ = c.Error(err)
 		return
 	}
 
-	logger.Logger.Info("got users")
-	logger.Logger.Debug("got users", slog.Any("users", users))
-	c.JSON(http.StatusOK, users)
+	logger.Logger.InfoContext(ctx, "got user time entries")
+	if page.Items == nil {
+		page.Items = []model.TimeEntry{}
+	}
+	c.JSON(http.StatusOK, TimeEntryListResponse{
+		Data: page.Items,
+		Meta: newPageMeta(filter, page),
+	})
+}
+
+// bindUserPage parses the user ID and the page of a listing nested in a user.
+func bindUserPage(c *gin.Context) (int, model.PageFilter, error) {
+	var filter model.PageFilter
+	userID, err := paramID(c, "user_id")
+	if err != nil {
+		return 0, filter, err
+	}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		return 0, filter, bindError(err)
+	}
+	if err := bindPage(&filter, ""); err != nil {
+		return 0, filter, err
+	}
+	logger.Logger.DebugContext(c.Request.Context(), "parsed page", slog.Int("user_id", userID), slog.Any("filter", filter))
+	return userID, filter, nil
 }
 
 // GetUserTimeSpent retrieves the time spent by the user based on the provided user ID and period.
@@ -89,97 +235,160 @@ func (h *userHandler) GetAllUsers(c *gin.Context) {
 // @Param start_period query string true "Start period" example("2023-30-12 00:00:00")
 // @Param end_period query string true "End period" example("2023-30-12 23:59:59")
 // @Success 200 {array} model.TaskTimeSpent "List of time spent"
-// @Failure 400 {object} ErrorResponse "Error message"
-// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "User not found"
+// @Failure 500 {object} ProblemDetails "Internal error"
 // @Router /user/{user_id}/time-spent [get]
 func (h *userHandler) GetUserTimeSpent(c *gin.Context) {
-	logger.Logger.Info("start get user time spent")
-	id := c.Param("user_id")
-	userID, err := strconv.Atoi(id)
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start get user time spent")
+	userID, err := paramID(c, "user_id")
 	if err != nil {
-		logger.Logger.Error("error parsing user id", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error parsing user id"))
+		_ = c.Error(err)
 		return
 	}
 
-	startPeriod, err := time.Parse("2006-01-02 15:04:05", c.Query("start_period"))
+	startPeriod, err := queryTime(c, "start_period")
 	if err != nil {
-		logger.Logger.Error("error parsing start date", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("Invalid start date format"))
+		_ = c.Error(err)
 		return
 	}
 
-	endPeriod, err := time.Parse("2006-01-02 15:04:05", c.Query("end_period"))
+	endPeriod, err := queryTime(c, "end_period")
 	if err != nil {
-		logger.Logger.Error("error parsing end date", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("Invalid end date format"))
+		_ = c.Error(err)
 		return
 	}
 
-	logger.Logger.Debug("parsed ",
+	logger.Logger.DebugContext(ctx, "parsed ",
 		slog.Int("user_id", userID),
 		slog.Any("start_period", startPeriod),
 		slog.Any("end_period", endPeriod))
 
-	timeSpent, err := h.service.GetUserTimeSpent(userID, startPeriod, endPeriod)
+	timeSpent, err := h.service.GetUserTimeSpent(ctx, userID, startPeriod, endPeriod)
 	if err != nil {
-		if errors.Is(err, model.ErrUserNotFound) {
-			logger.Logger.Warn("user not found", slog.Int("user_id", userID))
-			c.JSON(http.StatusBadRequest, newErrorResponse("user not found"))
-			return
-		}
-		logger.Logger.Error("error getting time spent", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error getting time spent"))
+		_ = c.Error(err)
 		return
 	}
-	logger.Logger.Info("got time spent")
-	logger.Logger.Debug("got time spent", slog.Any("time_spent", timeSpent))
+	logger.Logger.InfoContext(ctx, "got time spent")
+	logger.Logger.DebugContext(ctx, "got time spent", slog.Any("time_spent", timeSpent))
 	c.JSON(http.StatusOK, timeSpent)
 }
 
-// CreateUser creates a new user
+// GetUserHistory retrieves the history of profile changes of the user.
+// @Summary Get user profile history
+// @Tags Users
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Success 200 {array} model.UserHistoryEntry "List of profile changes"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "User not found"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/{user_id}/history [get]
+func (h *userHandler) GetUserHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start get user history")
+	userID, err := paramID(c, "user_id")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	history, err := h.service.GetUserHistory(ctx, userID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "got user history")
+	logger.Logger.DebugContext(ctx, "got user history", slog.Any("history", history))
+	c.JSON(http.StatusOK, history)
+}
+
+// GetUserAsOf retrieves the user as it was at the given date.
+// @Summary Get user as of date
+// @Tags Users
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param at query string true "Date" example("2023-30-12 00:00:00")
+// @Success 200 {object} model.User "User"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "User not found or did not exist at this date"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/{user_id}/snapshot [get]
+func (h *userHandler) GetUserAsOf(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start get user as of date")
+	userID, err := paramID(c, "user_id")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	at, err := queryTime(c, "at")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := h.service.GetUserAsOf(ctx, userID, at)
+	if err != nil {
+		if errors.Is(err, model.ErrUserVersionNotFound) {
+			err = model.NewError(model.CodeUserVersionNotFound, "user did not exist at this date", err)
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "got user as of date")
+	c.JSON(http.StatusOK, user)
+}
+
+// CreateUser creates a new user.
+// A retry with the same Idempotency-Key header gets the response of the original request.
 // @Summary Create a user
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param request body model.UserRequestBody true "User details"
+// @Param Idempotency-Key header string false "Unique key of the request, retries with the same key are not handled twice"
 // @Success 201 {object} SuccessResponse "User ID"
-// @Failure 400 {object} ErrorResponse "Error message"
-// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 409 {object} ProblemDetails "User with the passport exists (its ID is in existing_id) or request with the key is in progress"
+// @Failure 422 {object} ProblemDetails "Idempotency key was used for a different request"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Failure 502 {object} ProblemDetails "External API error"
 // @Router /user [post]
 func (h *userHandler) CreateUser(c *gin.Context) {
-	logger.Logger.Info("start create user")
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start create user")
 	var input model.UserRequestBody
-	if err := c.BindJSON(&input); err != nil {
-		logger.Logger.Error("error binding json", slog.String("error", err.Error()))
-		c.JSON(http.StatusOK, newErrorResponse("error binding json"))
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(bindError(err))
 		return
 	}
 
-	passportSerie, passportNumber, err := parsePassportNumberAndSerie(input.PassportNumber)
+	passportSerie, passportNumber, err := model.ParsePassport(input.PassportNumber)
 	if err != nil {
-		logger.Logger.Error("error parsing passport number", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error parsing passport number"))
+		_ = c.Error(fieldError("passportNumber", err.Error()))
 		return
 	}
 
-	user, err := h.externalApiInfo.GetUser(passportSerie, passportNumber)
+	user, err := h.externalApiInfo.GetUser(ctx, passportSerie, passportNumber)
 	if err != nil {
-		logger.Logger.Error("error getting user from external api", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error getting user from external api"))
+		_ = c.Error(model.NewError(model.CodeExternalAPIFailed, "error getting user from external api", err))
 		return
 	}
-	logger.Logger.Debug("got user from external api")
+	logger.Logger.DebugContext(ctx, "got user from external api")
 
-	userID, err := h.service.CreateUser(user)
+	userID, err := h.service.CreateUser(ctx, user, auditMeta(c))
 	if err != nil {
-		logger.Logger.Error("error creating user", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error creating user"))
+		_ = c.Error(err)
 		return
 	}
 
-	logger.Logger.Info("user created")
-	logger.Logger.Debug(fmt.Sprintf("user with id %d created", userID))
+	logger.Logger.InfoContext(ctx, "user created")
+	logger.Logger.DebugContext(ctx, fmt.Sprintf("user with id %d created", userID))
 	c.JSON(http.StatusCreated, newSuccessResponse(strconv.Itoa(userID)))
 }
 
@@ -191,35 +400,28 @@ func (h *userHandler) CreateUser(c *gin.Context) {
 // @Param user_id path int true "User ID"
 // @Param request body model.UserUpdateRequestBody true "User details"
 // @Success 200 {object} SuccessResponse "Message"
-// @Failure 400 {object} ErrorResponse "Error message"
-// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "User not found"
+// @Failure 500 {object} ProblemDetails "Internal error"
 // @Router /user/{user_id} [patch]
 func (h *userHandler) UpdateUser(c *gin.Context) {
-	logger.Logger.Info("start update user")
-	id := c.Param("user_id")
-	userID, err := strconv.Atoi(id)
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start update user")
+	userID, err := paramID(c, "user_id")
 	if err != nil {
-		logger.Logger.Error("error parsing user id", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error parsing user id"))
+		_ = c.Error(err)
 		return
 	}
 	var input model.UserUpdateRequestBody
-	if err := c.BindJSON(&input); err != nil {
-		logger.Logger.Error("error binding json", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error binding json"))
+	if err := c.ShouldBindJSON(&input); err != nil {
+		_ = c.Error(bindError(err))
 		return
 	}
-	logger.Logger.Debug("parsed input", slog.Any("input", input))
+	logger.Logger.DebugContext(ctx, "parsed input", slog.Any("input", input))
 
-	user, err := h.service.GetUser(userID)
+	user, err := h.service.GetUser(ctx, userID)
 	if err != nil {
-		if errors.Is(err, model.ErrUserNotFound) {
-			logger.Logger.Warn("user not found", slog.Int("user_id", userID))
-			c.JSON(http.StatusBadRequest, newErrorResponse("user not found"))
-			return
-		}
-		logger.Logger.Error("error getting user", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error getting user"))
+		_ = c.Error(err)
 		return
 	}
 	if input.Name != nil {
@@ -235,14 +437,13 @@ func (h *userHandler) UpdateUser(c *gin.Context) {
 		user.Address = *input.Address
 	}
 
-	if err := h.service.UpdateUser(user); err != nil {
-		logger.Logger.Error("error updating user", slog.String("error", err.Error()))
-		c.JSON(http.StatusInternalServerError, newErrorResponse("error updating user"))
+	if err := h.service.UpdateUser(ctx, user, auditMeta(c)); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	logger.Logger.Info("user updated")
-	logger.Logger.Debug(fmt.Sprintf("user with id %d updated", userID))
+	logger.Logger.InfoContext(ctx, "user updated")
+	logger.Logger.DebugContext(ctx, fmt.Sprintf("user with id %d updated", userID))
 	c.JSON(200, newSuccessResponse("user updated"))
 }
 
@@ -251,51 +452,166 @@ func (h *userHandler) UpdateUser(c *gin.Context) {
 // @Tags Users
 // @Param user_id path int true "User ID"
 // @Success 200 {object} SuccessResponse "Message"
-// @Failure 400 {object} ErrorResponse "Error message"
-// @Failure 500 {object} ErrorResponse "Error message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "User not found"
+// @Failure 500 {object} ProblemDetails "Internal error"
 // @Router /user/{user_id} [delete]
 func (h *userHandler) DeleteUser(c *gin.Context) {
-	logger.Logger.Info("start delete user")
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start delete user")
 
-	id := c.Param("user_id")
-	userID, err := strconv.Atoi(id)
+	userID, err := paramID(c, "user_id")
 	if err != nil {
-		logger.Logger.Error("error parsing user id", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error parsing user id"))
+		_ = c.Error(err)
 		return
 	}
 
-	if err := h.service.DeleteUser(userID); err != nil {
-		if errors.Is(err, model.ErrUserNotFound) {
-			logger.Logger.Warn("user not found", slog.Int("user_id", userID))
-			c.JSON(http.StatusBadRequest, newErrorResponse("user not found"))
-			return
-		}
-		logger.Logger.Error("error deleting user", slog.String("error", err.Error()))
-		c.JSON(http.StatusBadRequest, newErrorResponse("error deleting user"))
+	if err := h.service.DeleteUser(ctx, userID, auditMeta(c)); err != nil {
+		_ = c.Error(err)
 		return
 	}
 
-	logger.Logger.Info("user deleted")
-	logger.Logger.Debug(fmt.Sprintf("user with id %d deleted", userID))
+	logger.Logger.InfoContext(ctx, "user deleted")
+	logger.Logger.DebugContext(ctx, fmt.Sprintf("user with id %d deleted", userID))
 	c.JSON(http.StatusOK, newSuccessResponse("user deleted"))
 }
 
-func parsePassportNumberAndSerie(passportData string) (int, int, error) {
-	splitNumber := strings.Split(passportData, " ")
-	if len(splitNumber) != 2 {
-		return 0, 0, errors.New("invalid passport number")
+// RestoreUser restores a soft-deleted user
+// @Summary Restore a deleted user
+// @Tags Users
+// @Param user_id path int true "User ID"
+// @Success 200 {object} SuccessResponse "Message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 404 {object} ProblemDetails "Deleted user not found"
+// @Failure 409 {object} ProblemDetails "Active user with same passport exists"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/{user_id}/restore [post]
+func (h *userHandler) RestoreUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start restore user")
+
+	userID, err := paramID(c, "user_id")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.service.RestoreUser(ctx, userID, auditMeta(c)); err != nil {
+		switch {
+		case errors.Is(err, model.ErrUserNotFound):
+			err = model.NewError(model.CodeUserNotFound, "deleted user not found", err)
+		case errors.Is(err, model.ErrUserAlreadyExists):
+			err = model.NewError(model.CodeUserAlreadyExists, "active user with same passport exists", err)
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "user restored")
+	logger.Logger.DebugContext(ctx, fmt.Sprintf("user with id %d restored", userID))
+	c.JSON(http.StatusOK, newSuccessResponse("user restored"))
+}
+
+// HardDeleteUser permanently deletes the soft-deleted user with all tasks and time entries
+// @Summary Permanently delete a user
+// @Tags Users
+// @Param user_id path int true "User ID"
+// @Param X-Admin-Token header string true "Admin token"
+// @Success 200 {object} SuccessResponse "Message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 403 {object} ProblemDetails "Admin access required"
+// @Failure 404 {object} ProblemDetails "User not found or not deleted"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/{user_id}/permanent [delete]
+func (h *userHandler) HardDeleteUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start hard delete user")
+
+	userID, err := paramID(c, "user_id")
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := h.service.HardDeleteUser(ctx, userID, auditMeta(c)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	logger.Logger.InfoContext(ctx, "user permanently deleted")
+	logger.Logger.DebugContext(ctx, fmt.Sprintf("user with id %d permanently deleted", userID))
+	c.JSON(http.StatusOK, newSuccessResponse("user permanently deleted"))
+}
+
+// ExportUser exports all personal data stored about the user
+// @Summary Export user data
+// @Tags Users
+// @Produce json
+// @Param user_id path int true "User ID"
+// @Param X-Admin-Token header string true "Admin token"
+// @Success 200 {object} model.UserExport "User data archive"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 403 {object} ProblemDetails "Admin access required"
+// @Failure 404 {object} ProblemDetails "User not found"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/{user_id}/export [get]
+func (h *userHandler) ExportUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start export user")
+
+	userID, err := paramID(c, "user_id")
+	if err != nil {
+		_ = c.Error(err)
+		return
 	}
 
-	passportSerie, err := strconv.Atoi(splitNumber[0])
+	export, err := h.service.ExportUser(ctx, userID)
 	if err != nil {
-		return 0, 0, errors.New("invalid passport serie")
+		_ = c.Error(err)
+		return
 	}
 
-	passportNumber, err := strconv.Atoi(splitNumber[1])
+	logger.Logger.InfoContext(ctx, "user exported")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d.json"`, userID))
+	c.JSON(http.StatusOK, export)
+}
+
+// EraseUser anonymizes personal data of the user
+// @Summary Erase user personal data
+// @Tags Users
+// @Param user_id path int true "User ID"
+// @Param X-Admin-Token header string true "Admin token"
+// @Param X-Actor header string true "Who performs the erasure"
+// @Success 200 {object} SuccessResponse "Message"
+// @Failure 400 {object} ProblemDetails "Validation error"
+// @Failure 403 {object} ProblemDetails "Admin access required"
+// @Failure 404 {object} ProblemDetails "User not found or already erased"
+// @Failure 500 {object} ProblemDetails "Internal error"
+// @Router /user/{user_id}/erase [post]
+func (h *userHandler) EraseUser(c *gin.Context) {
+	ctx := c.Request.Context()
+	logger.Logger.InfoContext(ctx, "start erase user")
+
+	userID, err := paramID(c, "user_id")
 	if err != nil {
-		return 0, 0, errors.New("invalid passport number")
+		_ = c.Error(err)
+		return
+	}
+
+	if getActor(c) == "" {
+		_ = c.Error(fieldError(actorHeader, "header is required"))
+		return
+	}
+
+	meta := auditMeta(c)
+	if err := h.service.EraseUser(ctx, userID, meta); err != nil {
+		if errors.Is(err, model.ErrUserNotFound) {
+			err = model.NewError(model.CodeUserNotFound, "user not found or already erased", err)
+		}
+		_ = c.Error(err)
+		return
 	}
 
-	return passportSerie, passportNumber, nil
+	logger.Logger.InfoContext(ctx, "user erased", slog.Int("user_id", userID), slog.String("actor", meta.Actor))
+	c.JSON(http.StatusOK, newSuccessResponse("user erased"))
 }
@@ -0,0 +1,295 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCreateUser(t *testing.T) {
+	api := newTestAPI(t)
+
+	w := api.do(http.MethodPost, "/api/user/", `{"passportNumber": "1234 5678"}`)
+	expectStatus(t, w, http.StatusCreated)
+	if got := decode[SuccessResponse](t, w).Message; got != "1" {
+		t.Fatalf("expected user id 1, got %q", got)
+	}
+	if u, err := api.users.GetUser(context.Background(), 1); err != nil || u.Name != "Петр" || u.PassportSerie != 1234 {
+		t.Fatalf("user was not filled from external api: %+v, %v", u, err)
+	}
+
+	w = api.do(http.MethodPost, "/api/user/", `{"passportNumber": "1234 5678"}`)
+	if problem := expectProblem(t, w, http.StatusConflict, model.CodeUserAlreadyExists); problem.ExistingID != 1 {
+		t.Errorf("expected existing user id 1, got %+v", problem)
+	}
+}
+
+func TestCreateUserIdempotencyKey(t *testing.T) {
+	api := newTestAPI(t)
+	const body = `{"passportNumber": "1234 5678"}`
+
+	w := api.do(http.MethodPost, "/api/user/", body, idempotencyKeyHeader, "key-1")
+	expectStatus(t, w, http.StatusCreated)
+	first := w.Body.String()
+
+	w = api.do(http.MethodPost, "/api/user/", body, idempotencyKeyHeader, "key-1")
+	expectStatus(t, w, http.StatusCreated)
+	if w.Body.String() != first || w.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Fatalf("expected replay of %s, got %s with headers %v", first, w.Body.String(), w.Header())
+	}
+	if n := api.countUsers(); n != 1 {
+		t.Fatalf("retry must not create another user, got %d users", n)
+	}
+
+	expectProblem(t, api.do(http.MethodPost, "/api/user/", `{"passportNumber": "4321 8765"}`, idempotencyKeyHeader, "key-1"),
+		http.StatusUnprocessableEntity, model.CodeIdempotencyMismatch)
+
+	// Failed requests are not remembered, so a retry is handled again.
+	expectProblem(t, api.do(http.MethodPost, "/api/user/", `{}`, idempotencyKeyHeader, "key-2"), http.StatusBadRequest, model.CodeValidationFailed)
+	expectStatus(t, api.do(http.MethodPost, "/api/user/", `{"passportNumber": "4321 8765"}`, idempotencyKeyHeader, "key-2"), http.StatusCreated)
+
+	expectProblem(t, api.do(http.MethodPost, "/api/user/", body, idempotencyKeyHeader, strings.Repeat("k", 256)),
+		http.StatusBadRequest, model.CodeValidationFailed)
+}
+
+func TestCreateUserValidation(t *testing.T) {
+	api := newTestAPI(t)
+
+	tests := []struct {
+		name, body  string
+		status      int
+		code, field string
+	}{
+		{"missing passport", `{}`, http.StatusBadRequest, model.CodeValidationFailed, "passportNumber"},
+		{"malformed passport", `{"passportNumber": "12345678"}`, http.StatusBadRequest, model.CodeValidationFailed, "passportNumber"},
+		{"malformed json", `{"passportNumber":`, http.StatusBadRequest, model.CodeValidationFailed, "body"},
+		{"unknown passport", `{"passportNumber": "9999 9999"}`, http.StatusBadGateway, model.CodeExternalAPIFailed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := expectProblem(t, api.do(http.MethodPost, "/api/user/", tt.body), tt.status, tt.code)
+			if tt.field != "" && (len(problem.Errors) != 1 || problem.Errors[0].Field != tt.field) {
+				t.Errorf("expected error for field %s, got %+v", tt.field, problem.Errors)
+			}
+		})
+	}
+	if api.countUsers() != 0 {
+		t.Fatal("invalid requests must not create users")
+	}
+}
+
+func TestGetAllUsers(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(model.User{Name: "Петр"})
+	api.createUser(model.User{Name: "Иван"})
+	api.deleteUser(api.createUser(model.User{Name: "Петр"}))
+
+	w := api.do(http.MethodGet, "/api/user/?name=Петр", "")
+	expectStatus(t, w, http.StatusOK)
+	resp := decode[UserListResponse](t, w)
+	if len(resp.Data) != 1 || resp.Data[0].ID != 1 {
+		t.Fatalf("expected only active user 1, got %+v", resp.Data)
+	}
+	if resp.Meta != (PageMeta{Page: 1, PerPage: 10, Total: 1, TotalPages: 1}) {
+		t.Errorf("unexpected page meta %+v", resp.Meta)
+	}
+
+	w = api.do(http.MethodGet, "/api/user/?sort=id:desc&per_page=1&page=2", "")
+	expectStatus(t, w, http.StatusOK)
+	resp = decode[UserListResponse](t, w)
+	if len(resp.Data) != 1 || resp.Data[0].ID != 1 {
+		t.Fatalf("expected user 1 on the second page, got %+v", resp.Data)
+	}
+	prev := resp.Meta.PrevCursor
+	if resp.Meta != (PageMeta{Page: 2, PerPage: 1, Total: 2, TotalPages: 2, PrevCursor: prev}) || prev == "" {
+		t.Errorf("unexpected page meta %+v", resp.Meta)
+	}
+
+	w = api.do(http.MethodGet, "/api/user/?sort=id:desc&per_page=1&cursor="+prev, "")
+	expectStatus(t, w, http.StatusOK)
+	resp = decode[UserListResponse](t, w)
+	if len(resp.Data) != 1 || resp.Data[0].ID != 2 {
+		t.Fatalf("expected user 2 before the cursor, got %+v", resp.Data)
+	}
+	if next := resp.Meta.NextCursor; resp.Meta != (PageMeta{PerPage: 1, Total: 2, TotalPages: 2, NextCursor: next}) || next == "" {
+		t.Errorf("unexpected cursor page meta %+v", resp.Meta)
+	}
+
+	w = api.do(http.MethodGet, "/api/user/?name=Иван&page=5", "")
+	expectStatus(t, w, http.StatusOK)
+	if body := w.Body.String(); !strings.Contains(body, `"data":[]`) {
+		t.Errorf("expected empty data array, got %s", body)
+	}
+
+	expectProblem(t, api.do(http.MethodGet, "/api/user/?page=abc", ""), http.StatusBadRequest, model.CodeValidationFailed)
+	for _, query := range []string{"per_page=-1", "page=-2", "per_page=101"} {
+		expectProblem(t, api.do(http.MethodGet, "/api/user/?"+query, ""), http.StatusBadRequest, model.CodeValidationFailed)
+	}
+	for _, query := range []string{"cursor=abc", "cursor=" + prev} {
+		problem := expectProblem(t, api.do(http.MethodGet, "/api/user/?"+query, ""), http.StatusBadRequest, model.CodeValidationFailed)
+		if len(problem.Errors) != 1 || problem.Errors[0].Field != "cursor" {
+			t.Errorf("expected error for field cursor, got %+v", problem.Errors)
+		}
+	}
+	for _, sort := range []string{"password", "name:up", "name,name:desc"} {
+		problem := expectProblem(t, api.do(http.MethodGet, "/api/user/?sort="+sort, ""), http.StatusBadRequest, model.CodeValidationFailed)
+		if len(problem.Errors) != 1 || problem.Errors[0].Field != "sort" {
+			t.Errorf("expected error for field sort, got %+v", problem.Errors)
+		}
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.createUser(model.User{Name: "Петр"})
+	api.createTask(model.Task{UserID: user, Name: "report"})
+	review := api.createTask(model.Task{UserID: user, Name: "review"})
+	if err := api.tasks.StartTask(context.Background(), review, model.AuditMeta{}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := api.do(http.MethodGet, "/api/user/1", "")
+	expectStatus(t, w, http.StatusOK)
+	if body := w.Body.String(); strings.Contains(body, "tasks") || strings.Contains(body, "running") || strings.Contains(body, "totals") {
+		t.Errorf("expected no expansions without include, got %s", body)
+	}
+	if details := decode[model.UserDetails](t, w); details.User.Name != "Петр" {
+		t.Fatalf("unexpected user %+v", details)
+	}
+
+	w = api.do(http.MethodGet, "/api/user/1?include=tasks,running,totals", "")
+	expectStatus(t, w, http.StatusOK)
+	details := decode[model.UserDetails](t, w)
+	if details.Tasks == nil || len(*details.Tasks) != 2 || details.Totals == nil {
+		t.Fatalf("expected tasks and totals, got %+v", details)
+	}
+	if details.Running == nil || len(*details.Running) != 1 || (*details.Running)[0].TaskID != 2 {
+		t.Fatalf("expected running timer of task 2, got %+v", details.Running)
+	}
+
+	if _, err := api.tasks.StopTask(context.Background(), review, model.AuditMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	w = api.do(http.MethodGet, "/api/user/1?include=running", "")
+	expectStatus(t, w, http.StatusOK)
+	if body := w.Body.String(); !strings.Contains(body, `"running":[]`) {
+		t.Errorf("expected empty running list, got %s", body)
+	}
+
+	problem := expectProblem(t, api.do(http.MethodGet, "/api/user/1?include=tasks,history", ""), http.StatusBadRequest, model.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "include" {
+		t.Errorf("expected error for field include, got %+v", problem.Errors)
+	}
+	expectProblem(t, api.do(http.MethodGet, "/api/user/2", ""), http.StatusNotFound, model.CodeUserNotFound)
+	expectProblem(t, api.do(http.MethodGet, "/api/user/abc", ""), http.StatusBadRequest, model.CodeValidationFailed)
+}
+
+func TestGetAllUsersIncludeDeleted(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(model.User{Name: "Петр"})
+	api.deleteUser(api.createUser(model.User{Name: "Петр"}))
+
+	expectProblem(t, api.do(http.MethodGet, "/api/user/?include_deleted=true", ""), http.StatusForbidden, model.CodeForbidden)
+	expectProblem(t, api.do(http.MethodGet, "/api/user/?include_deleted=true", "", adminTokenHeader, "wrong"), http.StatusForbidden, model.CodeForbidden)
+
+	w := api.do(http.MethodGet, "/api/user/?include_deleted=true", "", adminTokenHeader, testAdminToken)
+	expectStatus(t, w, http.StatusOK)
+	resp := decode[UserListResponse](t, w)
+	if len(resp.Data) != 2 || !resp.Data[1].IsDeleted || resp.Meta.Total != 2 {
+		t.Fatalf("expected deleted user to be listed, got %+v", resp)
+	}
+}
+
+func TestGetUserTasks(t *testing.T) {
+	api := newTestAPI(t)
+	user := api.createUser(model.User{})
+	for range 3 {
+		api.createTask(model.Task{UserID: user, Name: "task"})
+	}
+
+	w := api.do(http.MethodGet, "/api/user/1/tasks?per_page=2", "")
+	expectStatus(t, w, http.StatusOK)
+	resp := decode[TaskListResponse](t, w)
+	if len(resp.Data) != 2 || resp.Data[0].ID != 1 || resp.Meta.NextCursor == "" || resp.Meta.Total != 3 {
+		t.Fatalf("unexpected first page %+v", resp)
+	}
+
+	w = api.do(http.MethodGet, "/api/user/1/tasks?per_page=2&cursor="+resp.Meta.NextCursor, "")
+	expectStatus(t, w, http.StatusOK)
+	resp = decode[TaskListResponse](t, w)
+	if len(resp.Data) != 1 || resp.Data[0].ID != 3 || resp.Meta.NextCursor != "" || resp.Meta.PrevCursor == "" {
+		t.Fatalf("unexpected last page %+v", resp)
+	}
+
+	expectProblem(t, api.do(http.MethodGet, "/api/user/2/tasks", ""), http.StatusNotFound, model.CodeUserNotFound)
+	expectProblem(t, api.do(http.MethodGet, "/api/user/1/tasks?per_page=1000", ""), http.StatusBadRequest, model.CodeValidationFailed)
+	expectStatus(t, api.do(http.MethodGet, "/api/user/1/time-entries", ""), http.StatusOK)
+}
+
+func TestUpdateUser(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(model.User{Name: "Петр", Address: "ул. Петрова, д. 1"})
+
+	w := api.do(http.MethodPatch, "/api/user/1", `{"address": "ул. Новая, д. 5"}`, actorHeader, "admin")
+	expectStatus(t, w, http.StatusOK)
+	if u, err := api.users.GetUser(context.Background(), 1); err != nil || u.Address != "ул. Новая, д. 5" || u.Name != "Петр" {
+		t.Fatalf("unexpected user after update: %+v, %v", u, err)
+	}
+	actor := "admin"
+	entries, err := api.audit.GetAuditLog(context.Background(), model.AuditFilter{Actor: &actor, Page: 1, PerPage: 10})
+	if err != nil || len(entries) != 1 || entries[0].Action != model.AuditActionUserUpdate {
+		t.Errorf("unexpected audit log %+v, %v", entries, err)
+	}
+
+	expectProblem(t, api.do(http.MethodPatch, "/api/user/2", `{"name": "Иван"}`), http.StatusNotFound, model.CodeUserNotFound)
+	expectProblem(t, api.do(http.MethodPatch, "/api/user/abc", `{"name": "Иван"}`), http.StatusBadRequest, model.CodeValidationFailed)
+}
+
+func TestDeleteAndRestoreUser(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(model.User{})
+
+	expectStatus(t, api.do(http.MethodDelete, "/api/user/1", ""), http.StatusOK)
+	expectProblem(t, api.do(http.MethodDelete, "/api/user/1", ""), http.StatusNotFound, model.CodeUserNotFound)
+
+	expectStatus(t, api.do(http.MethodPost, "/api/user/1/restore", ""), http.StatusOK)
+	expectProblem(t, api.do(http.MethodPost, "/api/user/1/restore", ""), http.StatusNotFound, model.CodeUserNotFound)
+}
+
+func TestGetUserTimeSpentValidation(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(model.User{})
+
+	problem := expectProblem(t, api.do(http.MethodGet, "/api/user/1/time-spent?start_period=yesterday", ""), http.StatusBadRequest, model.CodeValidationFailed)
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "start_period" {
+		t.Errorf("expected start_period error, got %+v", problem.Errors)
+	}
+
+	w := api.do(http.MethodGet, "/api/user/1/time-spent?start_period=2024-07-01%2000:00:00&end_period=2024-07-31%2023:59:59", "")
+	expectStatus(t, w, http.StatusOK)
+}
+
+func TestAdminRoutesRequireToken(t *testing.T) {
+	api := newTestAPI(t)
+	api.createUser(model.User{Name: "Петр"})
+
+	expectProblem(t, api.do(http.MethodDelete, "/api/user/1/permanent", ""), http.StatusForbidden, model.CodeForbidden)
+	expectProblem(t, api.do(http.MethodGet, "/api/user/1/export", "", adminTokenHeader, "wrong"), http.StatusForbidden, model.CodeForbidden)
+
+	w := api.do(http.MethodGet, "/api/user/1/export", "", adminTokenHeader, testAdminToken)
+	expectStatus(t, w, http.StatusOK)
+	if export := decode[model.UserExport](t, w); export.User.Name != "Петр" {
+		t.Errorf("unexpected export %+v", export)
+	}
+
+	expectProblem(t, api.do(http.MethodPost, "/api/user/1/erase", "", adminTokenHeader, testAdminToken), http.StatusBadRequest, model.CodeValidationFailed)
+	expectStatus(t, api.do(http.MethodPost, "/api/user/1/erase", "", adminTokenHeader, testAdminToken, actorHeader, "dpo"), http.StatusOK)
+
+	expectStatus(t, api.do(http.MethodDelete, "/api/user/1/permanent", "", adminTokenHeader, testAdminToken), http.StatusOK)
+	if _, err := api.users.GetUserWithDeleted(context.Background(), 1); !errors.Is(err, model.ErrUserNotFound) {
+		t.Fatalf("user was not deleted permanently: %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDown     = "down"
+	StatusDegraded = "degraded"
+)
+
+// Check is a single dependency check. Details are reported alongside the status when the check succeeds.
+type Check struct {
+	Name     string
+	Required bool
+	Fn       func(ctx context.Context) (details string, err error)
+}
+
+type ComponentStatus struct {
+	Status   string `json:"status"`
+	Required bool   `json:"required"`
+	Details  string `json:"details,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Latency  string `json:"latency"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// Ready reports whether all required components are up.
+func (r Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+}
+
+func NewChecker(timeout time.Duration, checks ...Check) *Checker {
+	return &Checker{checks: checks, timeout: timeout}
+}
+
+// Run executes all checks concurrently, each limited by the checker timeout.
+// A failed required check marks the report down, a failed optional one marks it degraded.
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(c.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[check.Name] = status
+			if status.Status == StatusUp {
+				return
+			}
+			if check.Required {
+				report.Status = StatusDown
+			} else if report.Status == StatusUp {
+				report.Status = StatusDegraded
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func (c *Checker) run(ctx context.Context, check Check) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check.Fn(ctx)
+	status := ComponentStatus{
+		Status:   StatusUp,
+		Required: check.Required,
+		Details:  details,
+		Latency:  time.Since(start).String(),
+	}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// PingDB checks that the database accepts connections.
+func PingDB(db *sqlx.DB) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		return "", db.PingContext(ctx)
+	}
+}
+
+// MigrationVersion checks that the applied goose migration version is at least minVersion.
+func MigrationVersion(db *sqlx.DB, minVersion int64) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		var version int64
+		q := `SELECT COALESCE(MAX(version_id), 0) FROM (
+			SELECT DISTINCT ON (version_id) version_id, is_applied FROM goose_db_version ORDER BY version_id, id DESC
+		) v WHERE is_applied`
+		if err := db.GetContext(ctx, &version, q); err != nil {
+			return "", fmt.Errorf("error getting migration version: %w", err)
+		}
+		details := fmt.Sprintf("version %d", version)
+		if version < minVersion {
+			return details, fmt.Errorf("migration version %d is older than required %d", version, minVersion)
+		}
+		return details, nil
+	}
+}
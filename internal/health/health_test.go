@@ -0,0 +1,43 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestChecker_Run(t *testing.T) {
+	up := func(ctx context.Context) (string, error) { return "ok", nil }
+	down := func(ctx context.Context) (string, error) { return "", errors.New("unreachable") }
+	slow := func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+
+	tests := []struct {
+		name   string
+		checks []Check
+		status string
+		ready  bool
+	}{
+		{"all up", []Check{{Name: "db", Required: true, Fn: up}}, StatusUp, true},
+		{"optional down", []Check{{Name: "db", Required: true, Fn: up}, {Name: "api", Fn: down}}, StatusDegraded, true},
+		{"required down", []Check{{Name: "db", Required: true, Fn: down}, {Name: "api", Fn: up}}, StatusDown, false},
+		{"required timeout", []Check{{Name: "db", Required: true, Fn: slow}}, StatusDown, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewChecker(50*time.Millisecond, tt.checks...).Run(context.Background())
+			if report.Status != tt.status {
+				t.Errorf("expected status %s, got %s", tt.status, report.Status)
+			}
+			if report.Ready() != tt.ready {
+				t.Errorf("expected ready %v, got %v", tt.ready, report.Ready())
+			}
+			if len(report.Components) != len(tt.checks) {
+				t.Errorf("expected %d components, got %d", len(tt.checks), len(report.Components))
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	namespace = "timetracker"
+
+	runningTimersTimeout = 2 * time.Second
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by route, method and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	ExternalAPIRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "external_api_requests_total",
+		Help:      "Number of external API calls by operation and outcome.",
+	}, []string{"operation", "outcome"})
+
+	ExternalAPIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "external_api_request_duration_seconds",
+		Help:      "Latency of external API calls by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	TasksStarted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_started_total",
+		Help:      "Number of started tasks.",
+	})
+
+	TasksStopped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_stopped_total",
+		Help:      "Number of stopped tasks.",
+	})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		ExternalAPIRequests,
+		ExternalAPIRequestDuration,
+		TasksStarted,
+		TasksStopped,
+	)
+}
+
+// Handler serves all registered metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exposes connection pool statistics of db.
+func RegisterDB(db *sqlx.DB) error {
+	return registry.Register(collectors.NewDBStatsCollector(db.DB, "postgres"))
+}
+
+// RegisterRunningTimers exposes the number of currently running timers, computed by count on every scrape.
+func RegisterRunningTimers(count func(ctx context.Context) (int, error)) error {
+	return registry.Register(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "running_timers",
+		Help:      "Number of currently running time entries.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), runningTimersTimeout)
+		defer cancel()
+
+		n, err := count(ctx)
+		if err != nil {
+			logger.Logger.Error("error counting running timers", slog.String("error", err.Error()))
+			return 0
+		}
+		return float64(n)
+	}))
+}
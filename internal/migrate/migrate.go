@@ -0,0 +1,144 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"github.com/usmonzodasomon/time-tracker/migrations"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"io"
+	"io/fs"
+	"log/slog"
+	"text/tabwriter"
+	"time"
+)
+
+// lockRetryPeriod is how often a replica retries to take the advisory lock held by another one.
+const lockRetryPeriod = 5 * time.Second
+
+// Migrator applies the migrations embedded into the binary.
+// Every command holds a Postgres advisory lock, so replicas started at the same time
+// apply migrations one after another instead of racing.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+func New(db *sql.DB, lockTimeout time.Duration) (*Migrator, error) {
+	failureThreshold := uint64(lockTimeout / lockRetryPeriod)
+	if failureThreshold == 0 {
+		failureThreshold = 1
+	}
+	locker, err := lock.NewPostgresSessionLocker(
+		lock.WithLockTimeout(uint64(lockRetryPeriod.Seconds()), failureThreshold),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating migration lock: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS, goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, fmt.Errorf("error loading migrations: %w", err)
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// NewSQLite creates a migrator for the SQLite schema. SQLite databases are local to a single process,
+// so no lock is taken.
+func NewSQLite(db *sql.DB) (*Migrator, error) {
+	fsys, err := fs.Sub(migrations.SQLiteFS, "sqlite")
+	if err != nil {
+		return nil, err
+	}
+	provider, err := goose.NewProvider(goose.DialectSQLite3, db, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("error loading migrations: %w", err)
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up(ctx context.Context) error {
+	results, err := m.provider.Up(ctx)
+	logResults(ctx, results...)
+	return err
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	result, err := m.provider.Down(ctx)
+	if result != nil {
+		logResults(ctx, result)
+	}
+	return err
+}
+
+// To migrates up or down so that version becomes the latest applied migration.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	current, err := m.provider.GetDBVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting database version: %w", err)
+	}
+
+	var results []*goose.MigrationResult
+	switch {
+	case version > current:
+		results, err = m.provider.UpTo(ctx, version)
+	case version < current:
+		results, err = m.provider.DownTo(ctx, version)
+	}
+	logResults(ctx, results...)
+	return err
+}
+
+// Status writes the state of every known migration to w.
+func (m *Migrator) Status(ctx context.Context, w io.Writer) error {
+	statuses, err := m.provider.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATE\tAPPLIED AT\tFILE")
+	for _, s := range statuses {
+		appliedAt := "-"
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Source.Version, s.State, appliedAt, s.Source.Path)
+	}
+	return tw.Flush()
+}
+
+func logResults(ctx context.Context, results ...*goose.MigrationResult) {
+	for _, r := range results {
+		attrs := []any{
+			slog.Int64("version", r.Source.Version),
+			slog.String("direction", r.Direction),
+			slog.Duration("duration", r.Duration),
+		}
+		if r.Error != nil {
+			logger.Logger.ErrorContext(ctx, "migration failed", append(attrs, slog.String("error", r.Error.Error()))...)
+			continue
+		}
+		logger.Logger.InfoContext(ctx, "migration applied", attrs...)
+	}
+}
+
+// Latest returns the version of the newest embedded Postgres migration.
+func Latest() (int64, error) {
+	names, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest int64
+	for _, name := range names {
+		version, err := goose.NumericComponent(name)
+		if err != nil {
+			return 0, fmt.Errorf("error parsing migration version of %s: %w", name, err)
+		}
+		latest = max(latest, version)
+	}
+	return latest, nil
+}
@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"database/sql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/usmonzodasomon/time-tracker/migrations"
+	"io/fs"
+	_ "modernc.org/sqlite"
+	"testing"
+	"time"
+)
+
+func TestNewLoadsEmbeddedMigrations(t *testing.T) {
+	// sql.Open does not connect, loading migrations must not need a database.
+	db, err := sql.Open("pgx", "postgres://localhost:1/none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := New(db, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, _ := fs.Glob(migrations.FS, "*.sql")
+	if got := len(m.provider.ListSources()); got != len(names) {
+		t.Errorf("expected %d migrations, got %d", len(names), got)
+	}
+}
+
+func TestNewSQLiteLoadsEmbeddedMigrations(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m, err := NewSQLite(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, _ := fs.Glob(migrations.SQLiteFS, "sqlite/*.sql")
+	if got := len(m.provider.ListSources()); got != len(names) || got == 0 {
+		t.Errorf("expected %d migrations, got %d", len(names), got)
+	}
+}
+
+func TestLatest(t *testing.T) {
+	latest, err := Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := fs.Glob(migrations.FS, "*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	last, err := goose.NumericComponent(names[len(names)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != last {
+		t.Errorf("expected latest version %d, got %d", last, latest)
+	}
+}
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditEntityUser = "user"
+	AuditEntityTask = "task"
+
+	AuditActionUserCreate     = "user.create"
+	AuditActionUserUpdate     = "user.update"
+	AuditActionUserDelete     = "user.delete"
+	AuditActionUserRestore    = "user.restore"
+	AuditActionUserHardDelete = "user.hard_delete"
+	AuditActionUserErase      = "user.erase"
+	AuditActionTaskCreate     = "task.create"
+	AuditActionTaskStart      = "task.start"
+	AuditActionTaskStop       = "task.stop"
+	AuditActionTaskStopAll    = "task.stop_all"
+)
+
+// AuditMeta describes who performed a mutation and within which request.
+type AuditMeta struct {
+	Actor     string
+	RequestID string
+}
+
+type AuditEntry struct {
+	ID         int             `db:"id" json:"id"`
+	Actor      string          `db:"actor" json:"actor"`
+	Action     string          `db:"action" json:"action"`
+	EntityType string          `db:"entity_type" json:"entity_type"`
+	EntityID   int             `db:"entity_id" json:"entity_id"`
+	Before     json.RawMessage `db:"before" json:"before" swaggertype:"object"`
+	After      json.RawMessage `db:"after" json:"after" swaggertype:"object"`
+	RequestID  *string         `db:"request_id" json:"request_id"`
+	CreatedAt  time.Time       `db:"created_at" json:"created_at"`
+}
+
+type AuditFilter struct {
+	EntityType *string    `form:"entity"`
+	EntityID   *int       `form:"entity_id"`
+	Actor      *string    `form:"actor"`
+	From       *time.Time `form:"from" time_format:"2006-01-02 15:04:05"`
+	To         *time.Time `form:"to" time_format:"2006-01-02 15:04:05"`
+
+	Page    int `form:"page" default:"1" binding:"omitempty,min=1"`
+	PerPage int `form:"per_page" default:"10" binding:"omitempty,min=1,max=100"`
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	UserIncludeTasks   = "tasks"
+	UserIncludeRunning = "running"
+	UserIncludeTotals  = "totals"
+)
+
+// UserIncludes are the expansions that can be requested together with a user.
+var UserIncludes = []string{UserIncludeTasks, UserIncludeRunning, UserIncludeTotals}
+
+// UserInclude selects the expansions embedded in UserDetails.
+type UserInclude struct {
+	Tasks   bool
+	Running bool
+	Totals  bool
+}
+
+// ParseUserInclude parses a comma separated list of UserIncludes, an empty string selects none.
+func ParseUserInclude(s string) (UserInclude, error) {
+	var include UserInclude
+	if s == "" {
+		return include, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		switch name := strings.TrimSpace(part); name {
+		case UserIncludeTasks:
+			include.Tasks = true
+		case UserIncludeRunning:
+			include.Running = true
+		case UserIncludeTotals:
+			include.Totals = true
+		default:
+			return UserInclude{}, fmt.Errorf("unknown expansion %q, must be one of %s", name, strings.Join(UserIncludes, ", "))
+		}
+	}
+	return include, nil
+}
+
+// UserDetails is a user with the expansions requested by UserInclude.
+// Expansions which were not requested are nil and omitted from JSON.
+type UserDetails struct {
+	User    User            `json:"user"`
+	Tasks   *[]Task         `json:"tasks,omitempty"`
+	Running *[]RunningTimer `json:"running,omitempty"`
+	Totals  *UserTotals     `json:"totals,omitempty"`
+}
+
+// RunningTimer is a time entry of the user's task which is not stopped yet.
+type RunningTimer struct {
+	TaskID         int       `json:"task_id" example:"1"`
+	TaskName       string    `json:"task_name" example:"Квартальный отчет"`
+	EntryID        int       `json:"entry_id" example:"10"`
+	StartTime      time.Time `json:"start_time"`
+	ElapsedMinutes int       `json:"elapsed_minutes" example:"25"`
+}
+
+// UserTotals is the time spent on all tasks of the user in whole minutes, including running timers.
+// The week starts on Monday.
+type UserTotals struct {
+	LifetimeMinutes int       `json:"lifetime_minutes" example:"5400"`
+	WeekMinutes     int       `json:"week_minutes" example:"600"`
+	WeekStart       time.Time `json:"week_start"`
+}
+
+// WeekStart returns the beginning of the Monday of the week of t in the location of t.
+func WeekStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+}
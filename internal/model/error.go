@@ -0,0 +1,66 @@
+package model
+
+import "strings"
+
+const (
+	CodeValidationFailed    = "VALIDATION_FAILED"
+	CodeForbidden           = "FORBIDDEN"
+	CodeUserNotFound        = "USER_NOT_FOUND"
+	CodeUserAlreadyExists   = "USER_ALREADY_EXISTS"
+	CodeUserVersionNotFound = "USER_VERSION_NOT_FOUND"
+	CodeTaskNotFound        = "TASK_NOT_FOUND"
+	CodeTaskAlreadyStarted  = "TASK_ALREADY_STARTED"
+	CodeTaskAlreadyStopped  = "TASK_ALREADY_STOPPED"
+	CodeExternalAPIFailed   = "EXTERNAL_API_FAILED"
+	CodeIdempotencyConflict = "IDEMPOTENCY_CONFLICT"
+	CodeIdempotencyMismatch = "IDEMPOTENCY_KEY_MISMATCH"
+	CodeImportJobNotFound   = "IMPORT_JOB_NOT_FOUND"
+	CodeImportQueueFull     = "IMPORT_QUEUE_FULL"
+	CodeInternal            = "INTERNAL"
+)
+
+// Error is a domain error with a machine-readable code.
+// Sentinel errors are compared with errors.Is, details are extracted with errors.As.
+type Error struct {
+	Code    string
+	Message string
+	Fields  []FieldError
+	// ExistingID is the ID of the resource a conflict is with, if it is known.
+	ExistingID int
+	Err        error
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	if len(e.Fields) > 0 {
+		fields := make([]string, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			fields = append(fields, f.Field+": "+f.Message)
+		}
+		return e.Message + ": " + strings.Join(fields, "; ")
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NewError(code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+func NewValidationError(fields ...FieldError) *Error {
+	return &Error{Code: CodeValidationFailed, Message: "validation failed", Fields: fields}
+}
+
+var (
+	ErrForbidden = &Error{Code: CodeForbidden, Message: "admin access required"}
+)
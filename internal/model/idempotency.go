@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+var (
+	ErrIdempotencyConflict = &Error{Code: CodeIdempotencyConflict, Message: "request with this idempotency key is in progress"}
+	ErrIdempotencyMismatch = &Error{Code: CodeIdempotencyMismatch, Message: "idempotency key was used for a different request"}
+)
+
+// IdempotencyRecord is the stored outcome of a request made with an Idempotency-Key header.
+// Status is zero while the request is in progress. Owner is a random token of the request which
+// reserved the key, only it may complete or delete the record.
+type IdempotencyRecord struct {
+	Key         string    `db:"key"`
+	Fingerprint string    `db:"fingerprint"`
+	Owner       string    `db:"owner"`
+	Status      int       `db:"status"`
+	Body        []byte    `db:"body"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+// Completed reports whether the response of the request is stored.
+func (r IdempotencyRecord) Completed() bool {
+	return r.Status != 0
+}
@@ -0,0 +1,131 @@
+package model
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrImportJobNotFound = &Error{Code: CodeImportJobNotFound, Message: "import job not found"}
+	ErrImportQueueFull   = &Error{Code: CodeImportQueueFull, Message: "too many imports are waiting, retry later"}
+)
+
+const (
+	ImportStatusCreated      = "created"
+	ImportStatusDuplicate    = "duplicate"
+	ImportStatusLookupFailed = "lookup_failed"
+	ImportStatusInvalid      = "invalid"
+	ImportStatusFailed       = "failed"
+)
+
+const (
+	ImportJobQueued  = "queued"
+	ImportJobRunning = "running"
+	ImportJobDone    = "done"
+	ImportJobFailed  = "failed"
+)
+
+// ImportRow is a passport read from a line of an import file.
+type ImportRow struct {
+	Line     int
+	Passport string
+}
+
+type ImportResult struct {
+	Line     int    `json:"line" example:"2"`
+	Passport string `json:"passport" example:"1234 567890"`
+	Status   string `json:"status" example:"created"`
+	UserID   int    `json:"user_id,omitempty" example:"1"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ImportReport has a result for every row of an import file in the order of the file.
+type ImportReport struct {
+	Created      int            `json:"created"`
+	Duplicate    int            `json:"duplicate"`
+	LookupFailed int            `json:"lookup_failed"`
+	Invalid      int            `json:"invalid"`
+	Failed       int            `json:"failed"`
+	Results      []ImportResult `json:"results"`
+}
+
+// Add records the result of a row in the report.
+func (r *ImportReport) Add(result ImportResult) {
+	switch result.Status {
+	case ImportStatusCreated:
+		r.Created++
+	case ImportStatusDuplicate:
+		r.Duplicate++
+	case ImportStatusLookupFailed:
+		r.LookupFailed++
+	case ImportStatusInvalid:
+		r.Invalid++
+	default:
+		r.Failed++
+	}
+	r.Results = append(r.Results, result)
+}
+
+// ImportJob is an import running in the background. Report is set once the job is done.
+type ImportJob struct {
+	ID         string        `json:"id" example:"9f86d081884c7d659a2feaa0c55ad015"`
+	Status     string        `json:"status" example:"done"`
+	Rows       int           `json:"rows" example:"2"`
+	CreatedAt  time.Time     `json:"created_at"`
+	FinishedAt *time.Time    `json:"finished_at,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Report     *ImportReport `json:"report,omitempty"`
+}
+
+// ParseImportCSV reads passports from CSV, one per line, either as a single "serie number"
+// column or as two columns with the serie and the number. A header line is skipped.
+func ParseImportCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		passport := strings.TrimSpace(strings.Join(record[:min(len(record), 2)], " "))
+		if passport == "" || (len(rows) == 0 && line == 1 && !startsWithDigit(passport)) {
+			continue
+		}
+		rows = append(rows, ImportRow{Line: line, Passport: passport})
+	}
+}
+
+func startsWithDigit(s string) bool {
+	return s[0] >= '0' && s[0] <= '9'
+}
+
+// ParsePassport parses a passport in the "serie number" form, e.g. "1234 567890".
+func ParsePassport(passport string) (serie, number int, err error) {
+	parts := strings.Split(passport, " ")
+	if len(parts) != 2 {
+		return 0, 0, errors.New("invalid passport number")
+	}
+
+	serie, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, errors.New("invalid passport serie")
+	}
+
+	number, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, errors.New("invalid passport number")
+	}
+
+	return serie, number, nil
+}
@@ -0,0 +1,134 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// MaxPerPage bounds the size of a page of any listing.
+const MaxPerPage = 100
+
+// PageFilter selects a page of a listing either by its number or, when Cursor is set,
+// by the position of the row next to it, which does not shift under concurrent inserts.
+type PageFilter struct {
+	Page    int    `form:"page" default:"1" binding:"omitempty,min=1"`
+	PerPage int    `form:"per_page" default:"10" binding:"omitempty,min=1,max=100"`
+	Cursor  string `form:"cursor"`
+
+	// After is decoded from Cursor, Page is ignored when it is set.
+	After *Cursor `form:"-" swaggerignore:"true"`
+}
+
+// Page is a part of a listing with the cursors of the neighbouring pages, empty when there is none.
+type Page[T any] struct {
+	Items      []T
+	Total      int
+	NextCursor string
+	PrevCursor string
+}
+
+// Cursor is a position in a listing ordered by sort keys. Clients get it as an opaque string.
+type Cursor struct {
+	// Keys are the sort keys of the row next to the page, the last one is always its id.
+	Keys []string `json:"k"`
+	// Before selects the page ending before the row instead of the one starting after it.
+	Before bool `json:"b,omitempty"`
+	// Sort is the sort parameter of the listing the cursor was made for.
+	Sort string `json:"s,omitempty"`
+}
+
+// Keyed is a row of a listing which can be ordered by sort fields.
+type Keyed interface {
+	SortKey(field string) any
+}
+
+var ErrInvalidCursor = NewValidationError(FieldError{Field: "cursor", Message: "is invalid or belongs to another listing"})
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || len(c.Keys) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// NewCursor returns the encoded position of row in a listing ordered by order.
+func NewCursor(row Keyed, order []SortField, before bool, sort string) string {
+	keys := make([]string, len(order))
+	for i, f := range order {
+		switch v := row.SortKey(f.Field).(type) {
+		case int:
+			keys[i] = strconv.Itoa(v)
+		case time.Time:
+			keys[i] = v.UTC().Format(time.RFC3339Nano)
+		default:
+			keys[i] = fmt.Sprint(v)
+		}
+	}
+	return Cursor{Keys: keys, Before: before, Sort: sort}.Encode()
+}
+
+// CursorValues converts the keys of c back to the values of the sort fields of T.
+func CursorValues[T Keyed](c *Cursor, order []SortField) ([]any, error) {
+	if len(c.Keys) != len(order) {
+		return nil, ErrInvalidCursor
+	}
+	var zero T
+	values := make([]any, len(order))
+	for i, f := range order {
+		switch zero.SortKey(f.Field).(type) {
+		case int:
+			n, err := strconv.Atoi(c.Keys[i])
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			values[i] = n
+		case time.Time:
+			t, err := time.Parse(time.RFC3339Nano, c.Keys[i])
+			if err != nil {
+				return nil, ErrInvalidCursor
+			}
+			values[i] = t
+		default:
+			values[i] = c.Keys[i]
+		}
+	}
+	return values, nil
+}
+
+// KeysetOrder completes sort fields with the id, so that every row has a unique position.
+// Fields after the id would not change the order and are dropped.
+func KeysetOrder(fields []SortField) []SortField {
+	if i := slices.IndexFunc(fields, func(f SortField) bool { return f.Field == "id" }); i >= 0 {
+		return fields[:i+1]
+	}
+	return append(slices.Clip(fields), SortField{Field: "id"})
+}
+
+// ReverseOrder flips the direction of every field, it is used to read a page before a cursor.
+func ReverseOrder(order []SortField) []SortField {
+	reversed := make([]SortField, len(order))
+	for i, f := range order {
+		reversed[i] = SortField{Field: f.Field, Desc: !f.Desc}
+	}
+	return reversed
+}
+
+// TaskOrder and TimeEntryOrder are the orders of the task and time entry listings.
+var (
+	TaskOrder      = []SortField{{Field: "id"}}
+	TimeEntryOrder = []SortField{{Field: "start_time"}, {Field: "id"}}
+)
@@ -0,0 +1,38 @@
+package model
+
+import (
+	"cmp"
+	"slices"
+)
+
+const (
+	SearchTypeUser = "user"
+	SearchTypeTask = "task"
+)
+
+type SearchFilter struct {
+	Q     string `form:"q" binding:"required"`
+	Limit int    `form:"limit" default:"20" binding:"omitempty,min=1,max=100"`
+}
+
+// SearchResult is a user or a task found by a search with its relevance, higher is more relevant.
+type SearchResult struct {
+	Type string  `json:"type" example:"user"`
+	Rank float64 `json:"rank" example:"0.42"`
+	User *User   `json:"user,omitempty"`
+	Task *Task   `json:"task,omitempty"`
+}
+
+func (r SearchResult) ID() int {
+	if r.User != nil {
+		return r.User.ID
+	}
+	return r.Task.ID
+}
+
+// SortSearchResults orders results from the most relevant one, equal ranks are ordered by type and id.
+func SortSearchResults(results []SearchResult) {
+	slices.SortFunc(results, func(a, b SearchResult) int {
+		return cmp.Or(cmp.Compare(b.Rank, a.Rank), cmp.Compare(a.Type, b.Type), cmp.Compare(a.ID(), b.ID()))
+	})
+}
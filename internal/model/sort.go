@@ -0,0 +1,43 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// SortField is a single column of a sort order.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// UserSortFields are the columns users can be sorted by.
+var UserSortFields = []string{"id", "passport_serie", "passport_number", "name", "surname", "patronymic", "address"}
+
+// ParseSort parses a comma separated list of field:asc or field:desc, the direction defaults to asc.
+// Only fields from allowed are accepted, an empty string gives no sort fields.
+func ParseSort(s string, allowed []string) ([]SortField, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var fields []SortField
+	for _, part := range strings.Split(s, ",") {
+		field, direction, _ := strings.Cut(strings.TrimSpace(part), ":")
+		if !slices.Contains(allowed, field) {
+			return nil, fmt.Errorf("unknown field %q, must be one of %s", field, strings.Join(allowed, ", "))
+		}
+		if slices.ContainsFunc(fields, func(f SortField) bool { return f.Field == field }) {
+			return nil, fmt.Errorf("field %q is repeated", field)
+		}
+		switch direction {
+		case "", "asc":
+			fields = append(fields, SortField{Field: field})
+		case "desc":
+			fields = append(fields, SortField{Field: field, Desc: true})
+		default:
+			return nil, fmt.Errorf("direction of %q must be asc or desc", field)
+		}
+	}
+	return fields, nil
+}
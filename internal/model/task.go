@@ -1,18 +1,42 @@
 package model
 
-import "errors"
+import "time"
 
 var (
-	ErrTaskNotFound       = errors.New("task not found")
-	ErrTaskAlreadyStarted = errors.New("task already started")
-	ErrTaskAlreadyStopped = errors.New("task already stopped")
+	ErrTaskNotFound       = &Error{Code: CodeTaskNotFound, Message: "task not found"}
+	ErrTaskAlreadyStarted = &Error{Code: CodeTaskAlreadyStarted, Message: "task already started"}
+	ErrTaskAlreadyStopped = &Error{Code: CodeTaskAlreadyStopped, Message: "task already stopped"}
 )
 
 type Task struct {
-	ID          int    `db:"id"`
-	UserID      int    `db:"user_id"`
-	Name        string `db:"name"`
-	Description string `db:"description"`
+	ID          int    `db:"id" json:"id"`
+	UserID      int    `db:"user_id" json:"user_id"`
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description"`
+}
+
+func (t Task) SortKey(field string) any {
+	if field == "id" {
+		return t.ID
+	}
+	return nil
+}
+
+type TimeEntry struct {
+	ID        int        `db:"id" json:"id"`
+	TaskID    int        `db:"task_id" json:"task_id"`
+	StartTime time.Time  `db:"start_time" json:"start_time"`
+	EndTime   *time.Time `db:"end_time" json:"end_time"`
+}
+
+func (e TimeEntry) SortKey(field string) any {
+	switch field {
+	case "id":
+		return e.ID
+	case "start_time":
+		return e.StartTime
+	}
+	return nil
 }
 
 type TaskTimeSpent struct {
@@ -1,11 +1,19 @@
 package model
 
-import "errors"
+import "time"
 
 var (
-	ErrUserNotFound = errors.New("user not found")
+	ErrUserNotFound        = &Error{Code: CodeUserNotFound, Message: "user not found"}
+	ErrUserAlreadyExists   = &Error{Code: CodeUserAlreadyExists, Message: "user already exists"}
+	ErrUserVersionNotFound = &Error{Code: CodeUserVersionNotFound, Message: "user version not found"}
 )
 
+// NewUserConflictError reports that the active user existingID already has the passport of a new user.
+// It wraps err, which is ErrUserAlreadyExists.
+func NewUserConflictError(existingID int, err error) *Error {
+	return &Error{Code: CodeUserAlreadyExists, Message: "user with same passport exists", ExistingID: existingID, Err: err}
+}
+
 type User struct {
 	ID             int    `db:"id"`
 	PassportSerie  int    `db:"passport_serie"`
@@ -14,10 +22,31 @@ type User struct {
 	Surname        string `db:"surname"`
 	Patronymic     string `db:"patronymic"`
 	Address        string `db:"address"`
+	IsDeleted      bool   `db:"is_deleted"`
+}
+
+func (u User) SortKey(field string) any {
+	switch field {
+	case "id":
+		return u.ID
+	case "passport_serie":
+		return u.PassportSerie
+	case "passport_number":
+		return u.PassportNumber
+	case "name":
+		return u.Name
+	case "surname":
+		return u.Surname
+	case "patronymic":
+		return u.Patronymic
+	case "address":
+		return u.Address
+	}
+	return nil
 }
 
 type UserRequestBody struct {
-	PassportNumber string `json:"passportNumber"`
+	PassportNumber string `json:"passportNumber" binding:"required"`
 }
 
 type UserTaskTimeSpent struct {
@@ -41,7 +70,76 @@ type UserFilter struct {
 	Surname        *string `form:"surname"`
 	Patronymic     *string `form:"patronymic"`
 	Address        *string `form:"address"`
+	// Q matches users whose name, surname, patronymic or address contain every word of it.
+	Q *string `form:"q"`
+	// Sort is a comma separated list of field:asc or field:desc, see UserSortFields.
+	Sort           string `form:"sort" example:"surname:asc,name:desc"`
+	IncludeDeleted bool   `form:"include_deleted"`
+
+	PageFilter
+
+	// SortBy is parsed from Sort, users are ordered by id after these fields.
+	SortBy []SortField `form:"-" swaggerignore:"true"`
+}
+
+// UserExport is an archive of everything stored about a single user.
+type UserExport struct {
+	ExportedAt time.Time    `json:"exported_at"`
+	User       ExportedUser `json:"user"`
+	Tasks      []TaskExport `json:"tasks"`
+}
+
+// ExportedUser is the profile of the user as written to the export archive.
+type ExportedUser struct {
+	ID             int    `json:"id"`
+	PassportSerie  int    `json:"passport_serie"`
+	PassportNumber int    `json:"passport_number"`
+	Name           string `json:"name"`
+	Surname        string `json:"surname"`
+	Patronymic     string `json:"patronymic"`
+	Address        string `json:"address"`
+	IsDeleted      bool   `json:"is_deleted"`
+}
+
+func NewExportedUser(u User) ExportedUser {
+	return ExportedUser{
+		ID:             u.ID,
+		PassportSerie:  u.PassportSerie,
+		PassportNumber: u.PassportNumber,
+		Name:           u.Name,
+		Surname:        u.Surname,
+		Patronymic:     u.Patronymic,
+		Address:        u.Address,
+		IsDeleted:      u.IsDeleted,
+	}
+}
+
+type TaskExport struct {
+	Task    Task        `json:"task"`
+	Entries []TimeEntry `json:"entries"`
+}
+
+// UserVersion is a snapshot of the user profile after a change.
+type UserVersion struct {
+	UserID     int       `db:"user_id" json:"user_id"`
+	Version    int       `db:"version" json:"version"`
+	Name       string    `db:"name" json:"name"`
+	Surname    string    `db:"surname" json:"surname"`
+	Patronymic string    `db:"patronymic" json:"patronymic"`
+	Address    string    `db:"address" json:"address"`
+	ChangedBy  string    `db:"changed_by" json:"changed_by"`
+	ChangedAt  time.Time `db:"changed_at" json:"changed_at"`
+}
+
+type UserFieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
 
-	Page    int `form:"page" default:"1"`
-	PerPage int `form:"per_page" default:"10"`
+type UserHistoryEntry struct {
+	Version   int               `json:"version"`
+	ChangedBy string            `json:"changed_by"`
+	ChangedAt time.Time         `json:"changed_at"`
+	Changes   []UserFieldChange `json:"changes"`
 }
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"strings"
+	"time"
+)
+
+type AuditRepoI interface {
+	GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error)
+}
+
+type AuditRepo struct {
+	db           *sqlx.DB
+	queryTimeout time.Duration
+}
+
+func NewAuditRepo(db *sqlx.DB, queryTimeout time.Duration) *AuditRepo {
+	return &AuditRepo{db: db, queryTimeout: queryTimeout}
+}
+
+func (r *AuditRepo) GetAuditLog(ctx context.Context, filter model.AuditFilter) (_ []model.AuditEntry, err error) {
+	ctx, finish := startQuery(ctx, "AuditRepo.GetAuditLog", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT id, actor, action, entity_type, entity_id, before, after, request_id, created_at FROM audit_log`
+
+	var conditions []string
+	var args []interface{}
+	argId := 1
+
+	if filter.EntityType != nil {
+		conditions = append(conditions, fmt.Sprintf("entity_type = $%d", argId))
+		args = append(args, *filter.EntityType)
+		argId++
+	}
+	if filter.EntityID != nil {
+		conditions = append(conditions, fmt.Sprintf("entity_id = $%d", argId))
+		args = append(args, *filter.EntityID)
+		argId++
+	}
+	if filter.Actor != nil {
+		conditions = append(conditions, fmt.Sprintf("actor = $%d", argId))
+		args = append(args, *filter.Actor)
+		argId++
+	}
+	if filter.From != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argId))
+		args = append(args, filter.From.UTC())
+		argId++
+	}
+	if filter.To != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at <= $%d", argId))
+		args = append(args, filter.To.UTC())
+		argId++
+	}
+
+	if len(conditions) > 0 {
+		q += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	q += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", argId, argId+1)
+	args = append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)
+
+	entries := []model.AuditEntry{}
+	if err := Conn(ctx, r.db).SelectContext(ctx, &entries, q, args...); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// writeAudit records a mutation in the audit log within the transaction of the mutation itself.
+// before and after are stored as JSON, nil values are stored as NULL.
+func writeAudit(ctx context.Context, tx *sqlx.Tx, meta model.AuditMeta, action, entityType string, entityID int, before, after any) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	var requestID *string
+	if meta.RequestID != "" {
+		requestID = &meta.RequestID
+	}
+
+	q := `INSERT INTO audit_log (actor, action, entity_type, entity_id, before, after, request_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	_, err = tx.ExecContext(ctx, q, meta.Actor, action, entityType, entityID, beforeJSON, afterJSON, requestID)
+	return err
+}
+
+// clearAuditSnapshots drops the snapshots of an entity from its audit log entries,
+// keeping the record of who did what and when.
+func clearAuditSnapshots(ctx context.Context, tx *sqlx.Tx, entityType string, entityID int) error {
+	q := `UPDATE audit_log SET before = NULL, after = NULL WHERE entity_type = $1 AND entity_id = $2`
+	_, err := tx.ExecContext(ctx, q, entityType, entityID)
+	return err
+}
+
+func auditJSON(v any) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling audit value: %w", err)
+	}
+	s := string(b)
+	return &s, nil
+}
@@ -0,0 +1,25 @@
+package repository_test
+
+import (
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/pgtest"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/repotest"
+	"testing"
+	"time"
+)
+
+// TestContract runs the repository contract against Postgres.
+func TestContract(t *testing.T) {
+	db := pgtest.Open(t)
+
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		pgtest.Truncate(t, db)
+		return repotest.Backend{
+			Users:       repository.NewUserRepo(db, 5*time.Second),
+			Tasks:       repository.NewTaskRepo(db, 5*time.Second),
+			Audit:       repository.NewAuditRepo(db, 5*time.Second),
+			Idempotency: repository.NewIdempotencyRepo(db, 5*time.Second),
+			Tx:          repository.NewTxManager(db),
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"time"
+)
+
+type IdempotencyRepoI interface {
+	// ReserveKey stores record as in progress unless its key is already stored and returns the stored record,
+	// reporting whether it was stored by this call. Records created before expiredBefore, and records still
+	// in progress since before abandonedBefore, e.g. left by a crashed process, are deleted first.
+	ReserveKey(ctx context.Context, record model.IdempotencyRecord, expiredBefore, abandonedBefore time.Time) (model.IdempotencyRecord, bool, error)
+	// CompleteKey stores the response of the request which reserved key as owner. Nothing is stored
+	// when the reservation was taken over by another request meanwhile.
+	CompleteKey(ctx context.Context, key, owner string, status int, body []byte) error
+	// DeleteKey forgets key reserved by owner, so that the request can be made with it again.
+	DeleteKey(ctx context.Context, key, owner string) error
+}
+
+type IdempotencyRepo struct {
+	db           *sqlx.DB
+	queryTimeout time.Duration
+}
+
+func NewIdempotencyRepo(db *sqlx.DB, queryTimeout time.Duration) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db, queryTimeout: queryTimeout}
+}
+
+func (r *IdempotencyRepo) ReserveKey(ctx context.Context, record model.IdempotencyRecord, expiredBefore, abandonedBefore time.Time) (_ model.IdempotencyRecord, reserved bool, err error) {
+	ctx, finish := startQuery(ctx, "IdempotencyRepo.ReserveKey", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var stored model.IdempotencyRecord
+	err = WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `DELETE FROM idempotency_keys WHERE created_at < $1 OR (status = 0 AND created_at < $2)`
+		if _, err := tx.ExecContext(ctx, q, expiredBefore, abandonedBefore); err != nil {
+			return err
+		}
+
+		// A concurrent insert of the same key blocks until the other transaction finishes,
+		// so the select below sees its record.
+		q = `INSERT INTO idempotency_keys (key, fingerprint, owner) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO NOTHING
+		RETURNING key, fingerprint, owner, status, body, created_at`
+		err := tx.GetContext(ctx, &stored, q, record.Key, record.Fingerprint, record.Owner)
+		if err == nil {
+			reserved = true
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		q = `SELECT key, fingerprint, owner, status, body, created_at FROM idempotency_keys WHERE key = $1`
+		return tx.GetContext(ctx, &stored, q, record.Key)
+	})
+	if err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	return stored, reserved, nil
+}
+
+func (r *IdempotencyRepo) CompleteKey(ctx context.Context, key, owner string, status int, body []byte) (err error) {
+	ctx, finish := startQuery(ctx, "IdempotencyRepo.CompleteKey", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `UPDATE idempotency_keys SET status = $3, body = $4 WHERE key = $1 AND owner = $2`
+	_, err = Conn(ctx, r.db).ExecContext(ctx, q, key, owner, status, body)
+	return err
+}
+
+func (r *IdempotencyRepo) DeleteKey(ctx context.Context, key, owner string) (err error) {
+	ctx, finish := startQuery(ctx, "IdempotencyRepo.DeleteKey", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	_, err = Conn(ctx, r.db).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = $1 AND owner = $2`, key, owner)
+	return err
+}
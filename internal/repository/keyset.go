@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"strings"
+)
+
+// Window describes how to read a page of a listing: by offset, or by keyset when
+// the page filter has a cursor.
+type Window struct {
+	// Order is the order to read rows in. For a page before the cursor it is reversed
+	// and the rows read have to be reversed back.
+	Order   []model.SortField
+	Reverse bool
+	// After holds the sort keys of the cursor row, rows are read strictly after them in Order.
+	After  []any
+	Offset int
+	Limit  int
+}
+
+// NewWindow returns the window of filter in a listing of T ordered by order, which must end with the id.
+func NewWindow[T model.Keyed](filter model.PageFilter, order []model.SortField) (Window, error) {
+	w := Window{Order: order, Limit: filter.PerPage}
+	if filter.After == nil {
+		w.Offset = (filter.Page - 1) * filter.PerPage
+		return w, nil
+	}
+	after, err := model.CursorValues[T](filter.After, order)
+	if err != nil {
+		return Window{}, err
+	}
+	w.After = after
+	if filter.After.Before {
+		w.Order = model.ReverseOrder(order)
+		w.Reverse = true
+	}
+	return w, nil
+}
+
+// KeysetCondition returns a condition selecting the rows after the keys of w.
+// bind adds an argument and returns its placeholder.
+func (w Window) KeysetCondition(bind func(any) string) string {
+	or := make([]string, len(w.Order))
+	for i, f := range w.Order {
+		and := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			and = append(and, w.Order[j].Field+" = "+bind(w.After[j]))
+		}
+		op := " > "
+		if f.Desc {
+			op = " < "
+		}
+		and = append(and, f.Field+op+bind(w.After[i]))
+		or[i] = "(" + strings.Join(and, " AND ") + ")"
+	}
+	return "(" + strings.Join(or, " OR ") + ")"
+}
+
+// OrderBy returns the ORDER BY list of w.
+func (w Window) OrderBy() string {
+	order := make([]string, len(w.Order))
+	for i, f := range w.Order {
+		direction := "ASC"
+		if f.Desc {
+			direction = "DESC"
+		}
+		order[i] = f.Field + " " + direction
+	}
+	return strings.Join(order, ", ")
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+)
+
+type AuditRepo struct {
+	store *Store
+}
+
+func NewAuditRepo(store *Store) *AuditRepo {
+	return &AuditRepo{store: store}
+}
+
+func (r *AuditRepo) GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	defer r.store.lock(ctx)()
+
+	// Entries are appended in order of creation, so the newest ones are at the end.
+	var matched []model.AuditEntry
+	for i := len(r.store.audit) - 1; i >= 0; i-- {
+		if e := r.store.audit[i]; matchAudit(e, filter) {
+			matched = append(matched, e)
+		}
+	}
+
+	entries := []model.AuditEntry{}
+	return append(entries, paginate(matched, filter.Page, filter.PerPage)...), nil
+}
+
+func matchAudit(e model.AuditEntry, filter model.AuditFilter) bool {
+	return (filter.EntityType == nil || e.EntityType == *filter.EntityType) &&
+		(filter.EntityID == nil || e.EntityID == *filter.EntityID) &&
+		(filter.Actor == nil || e.Actor == *filter.Actor) &&
+		(filter.From == nil || !e.CreatedAt.Before(*filter.From)) &&
+		(filter.To == nil || !e.CreatedAt.After(*filter.To))
+}
@@ -0,0 +1,50 @@
+package memory
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"maps"
+	"slices"
+	"time"
+)
+
+type IdempotencyRepo struct {
+	store *Store
+}
+
+func NewIdempotencyRepo(store *Store) *IdempotencyRepo {
+	return &IdempotencyRepo{store: store}
+}
+
+func (r *IdempotencyRepo) ReserveKey(ctx context.Context, record model.IdempotencyRecord, expiredBefore, abandonedBefore time.Time) (model.IdempotencyRecord, bool, error) {
+	defer r.store.lock(ctx)()
+
+	maps.DeleteFunc(r.store.idempotency, func(_ string, stored model.IdempotencyRecord) bool {
+		return stored.CreatedAt.Before(expiredBefore) || (!stored.Completed() && stored.CreatedAt.Before(abandonedBefore))
+	})
+	if stored, ok := r.store.idempotency[record.Key]; ok {
+		return stored, false, nil
+	}
+	record.Status, record.Body, record.CreatedAt = 0, nil, now()
+	r.store.idempotency[record.Key] = record
+	return record, true, nil
+}
+
+func (r *IdempotencyRepo) CompleteKey(ctx context.Context, key, owner string, status int, body []byte) error {
+	defer r.store.lock(ctx)()
+
+	if record, ok := r.store.idempotency[key]; ok && record.Owner == owner {
+		record.Status, record.Body = status, slices.Clone(body)
+		r.store.idempotency[key] = record
+	}
+	return nil
+}
+
+func (r *IdempotencyRepo) DeleteKey(ctx context.Context, key, owner string) error {
+	defer r.store.lock(ctx)()
+
+	if record, ok := r.store.idempotency[key]; ok && record.Owner == owner {
+		delete(r.store.idempotency, key)
+	}
+	return nil
+}
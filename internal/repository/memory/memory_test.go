@@ -0,0 +1,19 @@
+package memory
+
+import (
+	"github.com/usmonzodasomon/time-tracker/internal/repository/repotest"
+	"testing"
+)
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		store := NewStore()
+		return repotest.Backend{
+			Users:       NewUserRepo(store),
+			Tasks:       NewTaskRepo(store),
+			Audit:       NewAuditRepo(store),
+			Idempotency: NewIdempotencyRepo(store),
+			Tx:          NewTxManager(store),
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"cmp"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"slices"
+	"time"
+)
+
+// paginate returns the page of items, pages are numbered from 1. A nil result mirrors an empty SELECT.
+func paginate[T any](items []T, page, perPage int) []T {
+	offset := (page - 1) * perPage
+	if offset < 0 || perPage <= 0 || offset >= len(items) {
+		return nil
+	}
+	return items[offset:min(offset+perPage, len(items))]
+}
+
+// page sorts items in the order of w and returns its rows, like the SQL repositories do.
+func page[T model.Keyed](items []T, w repository.Window) []T {
+	slices.SortFunc(items, func(a, b T) int { return compareKeys(keys(a, w.Order), keys(b, w.Order), w.Order) })
+	if w.After != nil {
+		i := slices.IndexFunc(items, func(item T) bool { return compareKeys(keys(item, w.Order), w.After, w.Order) > 0 })
+		if i < 0 {
+			return nil
+		}
+		items = items[i:]
+	}
+	if w.Offset >= len(items) {
+		return nil
+	}
+	items = items[w.Offset:]
+	rows := slices.Clone(items[:min(w.Limit, len(items))])
+	if w.Reverse {
+		slices.Reverse(rows)
+	}
+	return rows
+}
+
+func keys(row model.Keyed, order []model.SortField) []any {
+	values := make([]any, len(order))
+	for i, f := range order {
+		values[i] = row.SortKey(f.Field)
+	}
+	return values
+}
+
+func compareKeys(a, b []any, order []model.SortField) int {
+	for i, f := range order {
+		var c int
+		switch v := a[i].(type) {
+		case int:
+			c = cmp.Compare(v, b[i].(int))
+		case string:
+			c = cmp.Compare(v, b[i].(string))
+		case time.Time:
+			c = v.Compare(b[i].(time.Time))
+		}
+		if f.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
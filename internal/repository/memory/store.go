@@ -0,0 +1,207 @@
+// Package memory implements the repositories in process memory with the same semantics as the Postgres ones.
+// It is meant for tests and demo runs: data is lost when the process exits.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+type userRow struct {
+	model.User
+	ErasedAt *time.Time
+}
+
+type erasure struct {
+	UserID      int
+	PerformedBy string
+	PerformedAt time.Time
+}
+
+// Store holds the tables shared by the repositories. A single mutex serializes access,
+// transactions hold it until they finish.
+type Store struct {
+	mu sync.Mutex
+
+	users       map[int]userRow
+	tasks       map[int]model.Task
+	entries     []model.TimeEntry
+	versions    []model.UserVersion
+	audit       []model.AuditEntry
+	erasures    []erasure
+	sequences   map[string]int
+	idempotency map[string]model.IdempotencyRecord
+}
+
+func NewStore() *Store {
+	return &Store{
+		users:       map[int]userRow{},
+		tasks:       map[int]model.Task{},
+		sequences:   map[string]int{},
+		idempotency: map[string]model.IdempotencyRecord{},
+	}
+}
+
+func (s *Store) nextID(table string) int {
+	s.sequences[table]++
+	return s.sequences[table]
+}
+
+// AddTimeEntry stores a time entry of the task with exact bounds, end may be zero for a running entry,
+// and returns its ID. It lets tests set up time tracked in the past, which the repositories never record.
+func (s *Store) AddTimeEntry(taskID int, start, end time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := model.TimeEntry{ID: s.nextID("time_entries"), TaskID: taskID, StartTime: start}
+	if !end.IsZero() {
+		entry.EndTime = &end
+	}
+	s.entries = append(s.entries, entry)
+	return entry.ID
+}
+
+// snapshot copies the tables so that a failed transaction can restore them.
+func (s *Store) snapshot() *Store {
+	return &Store{
+		users:       maps.Clone(s.users),
+		tasks:       maps.Clone(s.tasks),
+		entries:     slices.Clone(s.entries),
+		versions:    slices.Clone(s.versions),
+		audit:       slices.Clone(s.audit),
+		erasures:    slices.Clone(s.erasures),
+		sequences:   maps.Clone(s.sequences),
+		idempotency: maps.Clone(s.idempotency),
+	}
+}
+
+func (s *Store) restore(snap *Store) {
+	s.users, s.tasks, s.entries, s.versions = snap.users, snap.tasks, snap.entries, snap.versions
+	s.audit, s.erasures, s.sequences = snap.audit, snap.erasures, snap.sequences
+	s.idempotency = snap.idempotency
+}
+
+type txKey struct{}
+
+// lock acquires the store unless ctx belongs to a transaction of this store which already holds it.
+func (s *Store) lock(ctx context.Context) func() {
+	if tx, ok := ctx.Value(txKey{}).(*Store); ok && tx == s {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+// atomic runs fn under the lock and rolls back its changes when it fails,
+// like a repository method wrapped in its own transaction.
+func (s *Store) atomic(ctx context.Context, fn func() error) error {
+	unlock := s.lock(ctx)
+	defer unlock()
+
+	snap := s.snapshot()
+	if err := fn(); err != nil {
+		s.restore(snap)
+		return err
+	}
+	return nil
+}
+
+type TxManager struct {
+	store *Store
+}
+
+func NewTxManager(store *Store) *TxManager {
+	return &TxManager{store: store}
+}
+
+// WithinTx runs fn while holding the store, so that its repository calls are isolated from other goroutines,
+// and restores the previous state if fn fails or panics. A nested call joins the outer transaction.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if tx, ok := ctx.Value(txKey{}).(*Store); ok && tx == m.store {
+		return fn(ctx)
+	}
+
+	m.store.mu.Lock()
+	defer m.store.mu.Unlock()
+
+	snap := m.store.snapshot()
+	defer func() {
+		if p := recover(); p != nil {
+			m.store.restore(snap)
+			panic(p)
+		}
+	}()
+	if err := fn(context.WithValue(ctx, txKey{}, m.store)); err != nil {
+		m.store.restore(snap)
+		return err
+	}
+	return nil
+}
+
+func (s *Store) writeAudit(meta model.AuditMeta, action, entityType string, entityID int, before, after any) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	var requestID *string
+	if meta.RequestID != "" {
+		requestID = &meta.RequestID
+	}
+	s.audit = append(s.audit, model.AuditEntry{
+		ID:         s.nextID("audit_log"),
+		Actor:      meta.Actor,
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     beforeJSON,
+		After:      afterJSON,
+		RequestID:  requestID,
+		CreatedAt:  now(),
+	})
+	return nil
+}
+
+// clearAuditSnapshots drops the snapshots of an entity from its audit log entries.
+func (s *Store) clearAuditSnapshots(entityType string, entityID int) {
+	for i, e := range s.audit {
+		if e.EntityType == entityType && e.EntityID == entityID {
+			s.audit[i].Before, s.audit[i].After = nil, nil
+		}
+	}
+}
+
+func auditJSON(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling audit value: %w", err)
+	}
+	return b, nil
+}
+
+// now mimics timestamps stored by Postgres, which keeps microseconds and no monotonic clock.
+func now() time.Time {
+	return time.Now().Round(0).Truncate(time.Microsecond)
+}
+
+var (
+	_ repository.UserRepoI        = (*UserRepo)(nil)
+	_ repository.TaskRepoI        = (*TaskRepo)(nil)
+	_ repository.AuditRepoI       = (*AuditRepo)(nil)
+	_ repository.IdempotencyRepoI = (*IdempotencyRepo)(nil)
+	_ repository.TxManagerI       = (*TxManager)(nil)
+)
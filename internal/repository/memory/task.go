@@ -0,0 +1,220 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"slices"
+)
+
+type TaskRepo struct {
+	store *Store
+}
+
+func NewTaskRepo(store *Store) *TaskRepo {
+	return &TaskRepo{store: store}
+}
+
+func (r *TaskRepo) CreateTask(ctx context.Context, task model.Task, meta model.AuditMeta) (int, error) {
+	err := r.store.atomic(ctx, func() error {
+		if _, ok := r.store.users[task.UserID]; !ok {
+			return model.ErrUserNotFound
+		}
+		task.ID = r.store.nextID("tasks")
+		r.store.tasks[task.ID] = task
+		return r.store.writeAudit(meta, model.AuditActionTaskCreate, model.AuditEntityTask, task.ID, nil, task)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return task.ID, nil
+}
+
+func (r *TaskRepo) GetTask(ctx context.Context, id int) (model.Task, error) {
+	defer r.store.lock(ctx)()
+
+	task, ok := r.store.tasks[id]
+	if !ok || r.store.users[task.UserID].IsDeleted {
+		return model.Task{}, model.ErrTaskNotFound
+	}
+	return task, nil
+}
+
+func (r *TaskRepo) StartTask(ctx context.Context, taskID int, meta model.AuditMeta) error {
+	return r.store.atomic(ctx, func() error {
+		if _, ok := r.store.tasks[taskID]; !ok {
+			return model.ErrTaskNotFound
+		}
+		if slices.ContainsFunc(r.store.entries, func(e model.TimeEntry) bool { return e.TaskID == taskID && e.EndTime == nil }) {
+			return model.ErrTaskAlreadyStarted
+		}
+		entry := model.TimeEntry{ID: r.store.nextID("time_entries"), TaskID: taskID, StartTime: now()}
+		r.store.entries = append(r.store.entries, entry)
+		return r.store.writeAudit(meta, model.AuditActionTaskStart, model.AuditEntityTask, taskID, nil, entry)
+	})
+}
+
+func (r *TaskRepo) IsTaskStarted(ctx context.Context, taskID int) (bool, error) {
+	defer r.store.lock(ctx)()
+
+	return slices.ContainsFunc(r.store.entries, func(e model.TimeEntry) bool {
+		return e.TaskID == taskID && e.EndTime == nil
+	}), nil
+}
+
+func (r *TaskRepo) IsTaskStopped(ctx context.Context, taskID int) (bool, error) {
+	defer r.store.lock(ctx)()
+
+	return slices.ContainsFunc(r.store.entries, func(e model.TimeEntry) bool {
+		return e.TaskID == taskID && e.EndTime != nil
+	}), nil
+}
+
+// StopTask stops the running time entries of the task and returns how many were stopped.
+func (r *TaskRepo) StopTask(ctx context.Context, taskID int, meta model.AuditMeta) (int, error) {
+	var stopped int
+	err := r.store.atomic(ctx, func() error {
+		before, after := r.stopEntries(func(e model.TimeEntry) bool { return e.TaskID == taskID })
+		if len(after) == 0 {
+			return nil
+		}
+		stopped = len(after)
+		return r.store.writeAudit(meta, model.AuditActionTaskStop, model.AuditEntityTask, taskID, before, after)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return stopped, nil
+}
+
+// StopUserTasks stops all running time entries of the user and returns how many were stopped.
+func (r *TaskRepo) StopUserTasks(ctx context.Context, userID int, meta model.AuditMeta) (int, error) {
+	var stopped int
+	err := r.store.atomic(ctx, func() error {
+		before, after := r.stopEntries(func(e model.TimeEntry) bool { return r.store.tasks[e.TaskID].UserID == userID })
+		if len(after) == 0 {
+			return nil
+		}
+		stopped = len(after)
+		return r.store.writeAudit(meta, model.AuditActionTaskStopAll, model.AuditEntityUser, userID, before, after)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return stopped, nil
+}
+
+// stopEntries ends the running entries matching fn and returns them as they were before and after.
+func (r *TaskRepo) stopEntries(fn func(e model.TimeEntry) bool) (before, after []model.TimeEntry) {
+	end := now()
+	for i, e := range r.store.entries {
+		if e.EndTime != nil || !fn(e) {
+			continue
+		}
+		before = append(before, e)
+		e.EndTime = &end
+		r.store.entries[i] = e
+		after = append(after, e)
+	}
+	return before, after
+}
+
+func (r *TaskRepo) GetUserTasks(ctx context.Context, userID int) ([]model.Task, error) {
+	defer r.store.lock(ctx)()
+
+	var tasks []model.Task
+	for _, t := range r.store.tasks {
+		if t.UserID == userID {
+			tasks = append(tasks, t)
+		}
+	}
+	slices.SortFunc(tasks, func(a, b model.Task) int { return cmp.Compare(a.ID, b.ID) })
+	return tasks, nil
+}
+
+func (r *TaskRepo) GetUserTimeEntries(ctx context.Context, userID int) ([]model.TimeEntry, error) {
+	defer r.store.lock(ctx)()
+
+	var entries []model.TimeEntry
+	for _, e := range r.store.entries {
+		if r.store.tasks[e.TaskID].UserID == userID {
+			entries = append(entries, e)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b model.TimeEntry) int { return a.StartTime.Compare(b.StartTime) })
+	return entries, nil
+}
+
+// GetUserRunningEntries returns the time entries of the user's tasks which are not stopped yet.
+func (r *TaskRepo) GetUserRunningEntries(ctx context.Context, userID int) ([]model.TimeEntry, error) {
+	defer r.store.lock(ctx)()
+
+	var entries []model.TimeEntry
+	for _, e := range r.store.entries {
+		if e.EndTime == nil && r.store.tasks[e.TaskID].UserID == userID {
+			entries = append(entries, e)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b model.TimeEntry) int { return a.StartTime.Compare(b.StartTime) })
+	return entries, nil
+}
+
+// ListUserTasks returns the page of tasks of the user and the number of all of them.
+func (r *TaskRepo) ListUserTasks(ctx context.Context, userID int, filter model.PageFilter) ([]model.Task, int, error) {
+	defer r.store.lock(ctx)()
+
+	var tasks []model.Task
+	for _, t := range r.store.tasks {
+		if t.UserID == userID {
+			tasks = append(tasks, t)
+		}
+	}
+	window, err := repository.NewWindow[model.Task](filter, model.TaskOrder)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page(tasks, window), len(tasks), nil
+}
+
+// ListUserTimeEntries returns the page of time entries of the user's tasks and the number of all of them.
+func (r *TaskRepo) ListUserTimeEntries(ctx context.Context, userID int, filter model.PageFilter) ([]model.TimeEntry, int, error) {
+	defer r.store.lock(ctx)()
+
+	var entries []model.TimeEntry
+	for _, e := range r.store.entries {
+		if r.store.tasks[e.TaskID].UserID == userID {
+			entries = append(entries, e)
+		}
+	}
+	window, err := repository.NewWindow[model.TimeEntry](filter, model.TimeEntryOrder)
+	if err != nil {
+		return nil, 0, err
+	}
+	return page(entries, window), len(entries), nil
+}
+
+// SearchTasks returns the tasks of active users containing any of the words of query ranked by repository.WordRank.
+func (r *TaskRepo) SearchTasks(ctx context.Context, query string, limit int) ([]model.SearchResult, error) {
+	defer r.store.lock(ctx)()
+
+	var results []model.SearchResult
+	for _, t := range r.store.tasks {
+		if rank := repository.WordRank(query, repository.TaskText(t)); !r.store.users[t.UserID].IsDeleted && rank > 0 {
+			results = append(results, model.SearchResult{Type: model.SearchTypeTask, Rank: rank, Task: &t})
+		}
+	}
+	return repository.TopResults(results, limit), nil
+}
+
+func (r *TaskRepo) CountRunningTasks(ctx context.Context) (int, error) {
+	defer r.store.lock(ctx)()
+
+	count := 0
+	for _, e := range r.store.entries {
+		if e.EndTime == nil {
+			count++
+		}
+	}
+	return count, nil
+}
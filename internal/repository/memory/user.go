@@ -0,0 +1,317 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"slices"
+	"strings"
+	"time"
+)
+
+type UserRepo struct {
+	store *Store
+}
+
+func NewUserRepo(store *Store) *UserRepo {
+	return &UserRepo{store: store}
+}
+
+// GetAllUsers returns the page of users matching filter and the number of all matching users.
+func (r *UserRepo) GetAllUsers(ctx context.Context, filter model.UserFilter) ([]model.User, int, error) {
+	defer r.store.lock(ctx)()
+
+	var users []model.User
+	for _, u := range r.store.users {
+		if (u.IsDeleted && !filter.IncludeDeleted) || !matchUser(u.User, filter) {
+			continue
+		}
+		users = append(users, u.User)
+	}
+	window, err := repository.NewWindow[model.User](filter.PageFilter, model.KeysetOrder(filter.SortBy))
+	if err != nil {
+		return nil, 0, err
+	}
+	return page(users, window), len(users), nil
+}
+
+// SearchUsers returns the active users containing any of the words of query ranked by repository.WordRank.
+func (r *UserRepo) SearchUsers(ctx context.Context, query string, limit int) ([]model.SearchResult, error) {
+	defer r.store.lock(ctx)()
+
+	var results []model.SearchResult
+	for _, u := range r.store.users {
+		if rank := repository.WordRank(query, repository.UserText(u.User)); !u.IsDeleted && rank > 0 {
+			results = append(results, model.SearchResult{Type: model.SearchTypeUser, Rank: rank, User: &u.User})
+		}
+	}
+	return repository.TopResults(results, limit), nil
+}
+
+func matchUser(u model.User, filter model.UserFilter) bool {
+	contains := func(value string, substr *string) bool {
+		return substr == nil || strings.Contains(strings.ToLower(value), strings.ToLower(*substr))
+	}
+	return (filter.ID == nil || u.ID == *filter.ID) &&
+		(filter.PassportSerie == nil || u.PassportSerie == *filter.PassportSerie) &&
+		(filter.PassportNumber == nil || u.PassportNumber == *filter.PassportNumber) &&
+		contains(u.Name, filter.Name) &&
+		contains(u.Surname, filter.Surname) &&
+		contains(u.Patronymic, filter.Patronymic) &&
+		contains(u.Address, filter.Address) &&
+		matchWords(u, filter.Q)
+}
+
+// matchWords reports whether every word of q is contained in one of the text fields of u.
+func matchWords(u model.User, q *string) bool {
+	if q == nil {
+		return true
+	}
+	fields := strings.ToLower(strings.Join([]string{u.Name, u.Surname, u.Patronymic, u.Address}, "\x00"))
+	for _, word := range strings.Fields(strings.ToLower(*q)) {
+		if !strings.Contains(fields, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *UserRepo) GetUser(ctx context.Context, id int) (model.User, error) {
+	defer r.store.lock(ctx)()
+
+	u, ok := r.store.users[id]
+	if !ok || u.IsDeleted {
+		return model.User{}, model.ErrUserNotFound
+	}
+	return u.User, nil
+}
+
+// GetUserByPassport returns the active user with the passport.
+func (r *UserRepo) GetUserByPassport(ctx context.Context, passportSerie, passportNumber int) (model.User, error) {
+	defer r.store.lock(ctx)()
+
+	for _, u := range r.store.users {
+		if !u.IsDeleted && u.PassportSerie == passportSerie && u.PassportNumber == passportNumber {
+			return u.User, nil
+		}
+	}
+	return model.User{}, model.ErrUserNotFound
+}
+
+// GetUserTimeSpent sums the time entries of the user overlapping the period, counting only their part inside of it.
+func (r *UserRepo) GetUserTimeSpent(ctx context.Context, userID int, startPeriod, endPeriod time.Time) ([]model.TaskTimeSpent, error) {
+	defer r.store.lock(ctx)()
+
+	u, ok := r.store.users[userID]
+	if !ok || u.IsDeleted {
+		return nil, nil
+	}
+
+	current := now()
+	minutes := map[int]float64{}
+	for _, e := range r.store.entries {
+		task, ok := r.store.tasks[e.TaskID]
+		if !ok || task.UserID != userID {
+			continue
+		}
+		end := current
+		if e.EndTime != nil {
+			end = *e.EndTime
+		}
+		if !e.StartTime.Before(endPeriod) || !end.After(startPeriod) {
+			continue
+		}
+		start := e.StartTime
+		if start.Before(startPeriod) {
+			start = startPeriod
+		}
+		if end.After(endPeriod) {
+			end = endPeriod
+		}
+		minutes[e.TaskID] += end.Sub(start).Minutes()
+	}
+
+	var spent []model.TaskTimeSpent
+	for taskID, total := range minutes {
+		spent = append(spent, model.TaskTimeSpent{TaskID: taskID, TotalMinutes: total})
+	}
+	slices.SortFunc(spent, func(a, b model.TaskTimeSpent) int { return cmp.Compare(b.TotalMinutes, a.TotalMinutes) })
+	return spent, nil
+}
+
+func (r *UserRepo) CreateUser(ctx context.Context, user model.User, meta model.AuditMeta) (int, error) {
+	err := r.store.atomic(ctx, func() error {
+		if r.passportTaken(user, 0) {
+			return model.ErrUserAlreadyExists
+		}
+		user.ID = r.store.nextID("users")
+		user.IsDeleted = false
+		r.store.users[user.ID] = userRow{User: user}
+		r.writeUserVersion(user, meta)
+		return r.store.writeAudit(meta, model.AuditActionUserCreate, model.AuditEntityUser, user.ID, nil, user)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// passportTaken reports whether an active user other than exceptID has the passport of user.
+func (r *UserRepo) passportTaken(user model.User, exceptID int) bool {
+	for id, u := range r.store.users {
+		if id != exceptID && !u.IsDeleted &&
+			u.PassportSerie == user.PassportSerie && u.PassportNumber == user.PassportNumber {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *UserRepo) UpdateUser(ctx context.Context, user model.User, meta model.AuditMeta) error {
+	return r.store.atomic(ctx, func() error {
+		row, ok := r.store.users[user.ID]
+		if !ok {
+			return model.ErrUserNotFound
+		}
+		before := row.User
+		if !before.IsDeleted && r.passportTaken(user, user.ID) {
+			return model.ErrUserAlreadyExists
+		}
+
+		user.IsDeleted = before.IsDeleted
+		row.User = user
+		r.store.users[user.ID] = row
+		if user.Name != before.Name || user.Surname != before.Surname ||
+			user.Patronymic != before.Patronymic || user.Address != before.Address {
+			r.writeUserVersion(user, meta)
+		}
+		return r.store.writeAudit(meta, model.AuditActionUserUpdate, model.AuditEntityUser, user.ID, before, user)
+	})
+}
+
+func (r *UserRepo) DeleteUser(ctx context.Context, id int, meta model.AuditMeta) error {
+	return r.store.atomic(ctx, func() error {
+		row, ok := r.store.users[id]
+		if !ok {
+			return model.ErrUserNotFound
+		}
+		before := row.User
+		row.IsDeleted = true
+		r.store.users[id] = row
+		return r.store.writeAudit(meta, model.AuditActionUserDelete, model.AuditEntityUser, id, before, row.User)
+	})
+}
+
+func (r *UserRepo) RestoreUser(ctx context.Context, id int, meta model.AuditMeta) error {
+	return r.store.atomic(ctx, func() error {
+		row, ok := r.store.users[id]
+		if !ok || !row.IsDeleted || row.ErasedAt != nil {
+			return model.ErrUserNotFound
+		}
+		if r.passportTaken(row.User, id) {
+			return model.ErrUserAlreadyExists
+		}
+		before := row.User
+		row.IsDeleted = false
+		r.store.users[id] = row
+		return r.store.writeAudit(meta, model.AuditActionUserRestore, model.AuditEntityUser, id, before, row.User)
+	})
+}
+
+// HardDeleteUser removes the soft-deleted user with its tasks, time entries and profile history, like ON DELETE CASCADE.
+// Snapshots of the user are dropped from the audit log, so no personal data is left behind.
+func (r *UserRepo) HardDeleteUser(ctx context.Context, id int, meta model.AuditMeta) error {
+	return r.store.atomic(ctx, func() error {
+		if row, ok := r.store.users[id]; !ok || !row.IsDeleted {
+			return model.ErrUserNotFound
+		}
+		delete(r.store.users, id)
+		for taskID, t := range r.store.tasks {
+			if t.UserID == id {
+				delete(r.store.tasks, taskID)
+			}
+		}
+		r.store.entries = slices.DeleteFunc(r.store.entries, func(e model.TimeEntry) bool {
+			_, ok := r.store.tasks[e.TaskID]
+			return !ok
+		})
+		r.store.versions = slices.DeleteFunc(r.store.versions, func(v model.UserVersion) bool { return v.UserID == id })
+		r.store.clearAuditSnapshots(model.AuditEntityUser, id)
+		return r.store.writeAudit(meta, model.AuditActionUserHardDelete, model.AuditEntityUser, id, nil, nil)
+	})
+}
+
+func (r *UserRepo) GetUserWithDeleted(ctx context.Context, id int) (model.User, error) {
+	defer r.store.lock(ctx)()
+
+	row, ok := r.store.users[id]
+	if !ok {
+		return model.User{}, model.ErrUserNotFound
+	}
+	return row.User, nil
+}
+
+// EraseUser anonymizes personal fields of the user and drops the profile history and audit snapshots of the user.
+func (r *UserRepo) EraseUser(ctx context.Context, id int, meta model.AuditMeta) error {
+	return r.store.atomic(ctx, func() error {
+		row, ok := r.store.users[id]
+		if !ok || row.ErasedAt != nil {
+			return model.ErrUserNotFound
+		}
+		erasedAt := now()
+		r.store.users[id] = userRow{User: model.User{ID: id, IsDeleted: true}, ErasedAt: &erasedAt}
+		r.store.erasures = append(r.store.erasures, erasure{UserID: id, PerformedBy: meta.Actor, PerformedAt: erasedAt})
+		r.store.versions = slices.DeleteFunc(r.store.versions, func(v model.UserVersion) bool { return v.UserID == id })
+		r.store.clearAuditSnapshots(model.AuditEntityUser, id)
+		return r.store.writeAudit(meta, model.AuditActionUserErase, model.AuditEntityUser, id, nil, nil)
+	})
+}
+
+func (r *UserRepo) GetUserHistory(ctx context.Context, userID int) ([]model.UserVersion, error) {
+	defer r.store.lock(ctx)()
+
+	versions := []model.UserVersion{}
+	for _, v := range r.store.versions {
+		if v.UserID == userID {
+			versions = append(versions, v)
+		}
+	}
+	slices.SortFunc(versions, func(a, b model.UserVersion) int { return cmp.Compare(a.Version, b.Version) })
+	return versions, nil
+}
+
+func (r *UserRepo) GetUserVersionAt(ctx context.Context, userID int, at time.Time) (model.UserVersion, error) {
+	defer r.store.lock(ctx)()
+
+	var found *model.UserVersion
+	for i, v := range r.store.versions {
+		if v.UserID == userID && !v.ChangedAt.After(at) && (found == nil || v.Version > found.Version) {
+			found = &r.store.versions[i]
+		}
+	}
+	if found == nil {
+		return model.UserVersion{}, model.ErrUserVersionNotFound
+	}
+	return *found, nil
+}
+
+// writeUserVersion stores the current profile of the user as the next version of its history.
+func (r *UserRepo) writeUserVersion(user model.User, meta model.AuditMeta) {
+	version := 0
+	for _, v := range r.store.versions {
+		if v.UserID == user.ID {
+			version = max(version, v.Version)
+		}
+	}
+	r.store.versions = append(r.store.versions, model.UserVersion{
+		UserID:     user.ID,
+		Version:    version + 1,
+		Name:       user.Name,
+		Surname:    user.Surname,
+		Patronymic: user.Patronymic,
+		Address:    user.Address,
+		ChangedBy:  meta.Actor,
+		ChangedAt:  now(),
+	})
+}
@@ -0,0 +1,86 @@
+// Package pgtest prepares a Postgres database for integration tests.
+package pgtest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/jmoiron/sqlx"
+	"github.com/usmonzodasomon/time-tracker/internal/migrate"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"github.com/usmonzodasomon/time-tracker/pkg/postgres"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+)
+
+// DSNEnv names the environment variable with the connection string of the test database.
+const DSNEnv = "TEST_POSTGRES_DSN"
+
+// Open connects to the database from TEST_POSTGRES_DSN and applies the migrations into a throwaway schema,
+// which is dropped when the test finishes. The test is skipped when the variable is not set.
+func Open(t testing.TB) *sqlx.DB {
+	t.Helper()
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", DSNEnv)
+	}
+	if logger.Logger == nil {
+		logger.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	cfg, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse %s: %v", DSNEnv, err)
+	}
+	admin := sqlx.NewDb(stdlib.OpenDB(*cfg), "pgx")
+	defer admin.Close()
+
+	schema := "test_" + randomSuffix(t)
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin := sqlx.NewDb(stdlib.OpenDB(*cfg), "pgx")
+		defer admin.Close()
+		if _, err := admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`); err != nil {
+			t.Errorf("drop schema %s: %v", schema, err)
+		}
+	})
+
+	cfg = cfg.Copy()
+	// public stays visible for extensions such as pg_trgm installed database-wide.
+	cfg.RuntimeParams["search_path"] = schema + ",public"
+	db := postgres.Open(cfg)
+	t.Cleanup(func() { _ = db.Close() })
+
+	migrator, err := migrate.New(db.DB, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// Truncate empties all tables of the application and restarts their ids.
+func Truncate(t testing.TB, db *sqlx.DB) {
+	t.Helper()
+	q := `TRUNCATE users, tasks, time_entries, user_versions, user_erasures, audit_log RESTART IDENTITY CASCADE`
+	if _, err := db.Exec(q); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+}
+
+func randomSuffix(t testing.TB) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"time"
+)
+
+var dbSystem = attribute.String("db.system", "postgresql")
+
+func startQuery(ctx context.Context, name string, timeout time.Duration) (context.Context, func(err error)) {
+	return StartQuery(ctx, dbSystem, name, timeout)
+}
+
+// StartQuery starts a span for a repository method of the database named by system
+// and bounds ctx by timeout when it is positive.
+// The returned function must be called with the result of the method once it completes.
+func StartQuery(ctx context.Context, system attribute.KeyValue, name string, timeout time.Duration) (context.Context, func(err error)) {
+	ctx, span := tracing.Start(ctx, name, system)
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func(err error) {
+		cancel()
+		tracing.End(span, err)
+	}
+}
@@ -0,0 +1,889 @@
+// Package repotest is a contract test suite shared by all repository backends,
+// so that each of them behaves the same way for the services.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"github.com/usmonzodasomon/time-tracker/internal/service"
+	"math"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Backend is a set of repositories sharing one empty storage.
+type Backend struct {
+	Users       repository.UserRepoI
+	Tasks       repository.TaskRepoI
+	Audit       repository.AuditRepoI
+	Idempotency repository.IdempotencyRepoI
+	Tx          repository.TxManagerI
+}
+
+// Run runs the contract suite. newBackend is called for every test and must return empty storage.
+func Run(t *testing.T, newBackend func(t *testing.T) Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b Backend)
+	}{
+		{"CreateAndGetUser", testCreateAndGetUser},
+		{"GetAllUsersFilters", testGetAllUsersFilters},
+		{"KeysetPages", testKeysetPages},
+		{"SoftDeleteAndRestore", testSoftDeleteAndRestore},
+		{"HardDelete", testHardDelete},
+		{"UpdateAndHistory", testUpdateAndHistory},
+		{"EraseUser", testEraseUser},
+		{"TaskLifecycle", testTaskLifecycle},
+		{"ConcurrentStart", testConcurrentStart},
+		{"StopUserTasks", testStopUserTasks},
+		{"TimeSpent", testTimeSpent},
+		{"TimeSpentAcrossBoundary", testTimeSpentAcrossBoundary},
+		{"Search", testSearch},
+		{"AuditLog", testAuditLog},
+		{"IdempotencyKeys", testIdempotencyKeys},
+		{"Transactions", testTransactions},
+		{"ServiceRollback", testServiceRollback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newBackend(t))
+		})
+	}
+}
+
+var (
+	meta  = model.AuditMeta{Actor: "tester", RequestID: "req-1"}
+	petr  = model.User{PassportSerie: 1234, PassportNumber: 5678, Name: "Петр", Surname: "Петров", Patronymic: "Петрович", Address: "ул. Петрова, д. 1"}
+	ivan  = model.User{PassportSerie: 4321, PassportNumber: 8765, Name: "Иван", Surname: "Иванов", Patronymic: "Иванович", Address: "ул. Иванова, д. 2"}
+	sidor = model.User{PassportSerie: 1111, PassportNumber: 2222, Name: "Сидор", Surname: "Сидоров", Patronymic: "Сидорович", Address: "ул. Сидорова, д. 3"}
+)
+
+func createUser(t *testing.T, b Backend, user model.User) model.User {
+	t.Helper()
+	id, err := b.Users.CreateUser(context.Background(), user, meta)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	user.ID = id
+	return user
+}
+
+func createTask(t *testing.T, b Backend, userID int, name string) model.Task {
+	t.Helper()
+	task := model.Task{UserID: userID, Name: name, Description: name + " description"}
+	id, err := b.Tasks.CreateTask(context.Background(), task, meta)
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	task.ID = id
+	return task
+}
+
+// stopTask stops the task, expecting its running entry to be stopped.
+func stopTask(t *testing.T, b Backend, taskID int) {
+	t.Helper()
+	stopped, err := b.Tasks.StopTask(context.Background(), taskID, meta)
+	if err != nil || stopped != 1 {
+		t.Fatalf("StopTask: expected one entry to be stopped, got %d, %v", stopped, err)
+	}
+}
+
+func expectErr(t *testing.T, err, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("expected %v, got %v", target, err)
+	}
+}
+
+func noErr(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testCreateAndGetUser(t *testing.T, b Backend) {
+	ctx := context.Background()
+	user := createUser(t, b, petr)
+
+	got, err := b.Users.GetUser(ctx, user.ID)
+	noErr(t, err)
+	if got != user {
+		t.Fatalf("expected %+v, got %+v", user, got)
+	}
+
+	_, err = b.Users.CreateUser(ctx, petr, meta)
+	expectErr(t, err, model.ErrUserAlreadyExists)
+	got, err = b.Users.GetUserByPassport(ctx, petr.PassportSerie, petr.PassportNumber)
+	noErr(t, err)
+	if got != user {
+		t.Fatalf("expected %+v by passport, got %+v", user, got)
+	}
+	_, err = b.Users.GetUserByPassport(ctx, petr.PassportSerie, petr.PassportNumber+1)
+	expectErr(t, err, model.ErrUserNotFound)
+
+	_, err = b.Users.GetUser(ctx, user.ID+100)
+	expectErr(t, err, model.ErrUserNotFound)
+	_, err = b.Users.GetUserWithDeleted(ctx, user.ID+100)
+	expectErr(t, err, model.ErrUserNotFound)
+}
+
+func testGetAllUsersFilters(t *testing.T, b Backend) {
+	ctx := context.Background()
+	p := createUser(t, b, petr)
+	i := createUser(t, b, ivan)
+	s := createUser(t, b, sidor)
+	noErr(t, b.Users.DeleteUser(ctx, s.ID, meta))
+
+	byName := []model.SortField{{Field: "name"}}
+	tests := []struct {
+		name   string
+		filter model.UserFilter
+		want   []int
+		total  int
+	}{
+		{"all active", model.UserFilter{}, []int{p.ID, i.ID}, 2},
+		{"name is case insensitive substring", model.UserFilter{Name: ptr("пет")}, []int{p.ID}, 1},
+		{"address substring", model.UserFilter{Address: ptr("Иванова")}, []int{i.ID}, 1},
+		{"passport", model.UserFilter{PassportSerie: ptr(4321), PassportNumber: ptr(8765)}, []int{i.ID}, 1},
+		{"id", model.UserFilter{ID: ptr(p.ID)}, []int{p.ID}, 1},
+		{"deleted are hidden", model.UserFilter{Name: ptr("Сидор")}, nil, 0},
+		{"deleted are included on request", model.UserFilter{Name: ptr("Сидор"), IncludeDeleted: true}, []int{s.ID}, 1},
+		{"q matches any text field", model.UserFilter{Q: ptr("ИВАНОВА")}, []int{i.ID}, 1},
+		{"q requires every word", model.UserFilter{Q: ptr("петр  ул. д. 1")}, []int{p.ID}, 1},
+		{"q without matches", model.UserFilter{Q: ptr("петр иван")}, nil, 0},
+		{"sort by name", model.UserFilter{SortBy: byName, IncludeDeleted: true}, []int{i.ID, p.ID, s.ID}, 3},
+		{"sort descending", model.UserFilter{SortBy: []model.SortField{{Field: "passport_serie", Desc: true}}, IncludeDeleted: true}, []int{i.ID, p.ID, s.ID}, 3},
+		{"sort by id descending", model.UserFilter{SortBy: []model.SortField{{Field: "id", Desc: true}}}, []int{i.ID, p.ID}, 2},
+		{"second page", model.UserFilter{PageFilter: model.PageFilter{Page: 2, PerPage: 1}}, []int{i.ID}, 2},
+		{"sorted page", model.UserFilter{SortBy: byName, IncludeDeleted: true, PageFilter: model.PageFilter{Page: 2, PerPage: 2}}, []int{s.ID}, 3},
+		{"page after the end", model.UserFilter{PageFilter: model.PageFilter{Page: 3, PerPage: 1}}, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.filter.Page == 0 {
+				tt.filter.Page, tt.filter.PerPage = 1, 10
+			}
+			users, total, err := b.Users.GetAllUsers(ctx, tt.filter)
+			noErr(t, err)
+			var ids []int
+			for _, u := range users {
+				ids = append(ids, u.ID)
+				if u.IsDeleted != (u.ID == s.ID) {
+					t.Errorf("unexpected deleted flag of %+v", u)
+				}
+			}
+			if len(ids) != len(tt.want) {
+				t.Fatalf("expected users %v, got %v", tt.want, ids)
+			}
+			for k := range ids {
+				if ids[k] != tt.want[k] {
+					t.Fatalf("expected users %v, got %v", tt.want, ids)
+				}
+			}
+			if total != tt.total {
+				t.Errorf("expected total %d, got %d", tt.total, total)
+			}
+		})
+	}
+}
+
+func testKeysetPages(t *testing.T, b Backend) {
+	ctx := context.Background()
+	p := createUser(t, b, petr)
+	i := createUser(t, b, ivan)
+	s := createUser(t, b, sidor)
+	i2 := createUser(t, b, model.User{PassportSerie: 5555, PassportNumber: 6666, Name: "Иван", Surname: "Сидоров"})
+
+	cursor := func(row model.Keyed, order []model.SortField, before bool) *model.Cursor {
+		c, err := model.DecodeCursor(model.NewCursor(row, order, before, ""))
+		noErr(t, err)
+		return c
+	}
+	byName := model.KeysetOrder([]model.SortField{{Field: "name"}})
+	byNameDesc := model.KeysetOrder([]model.SortField{{Field: "name", Desc: true}})
+	tests := []struct {
+		name  string
+		sort  []model.SortField
+		after *model.Cursor
+		want  []int
+	}{
+		{"first page", byName, nil, []int{i.ID, i2.ID}},
+		{"after equal names", byName, cursor(i2, byName, false), []int{p.ID, s.ID}},
+		{"between equal names", byName, cursor(i, byName, false), []int{i2.ID, p.ID}},
+		{"after the end", byName, cursor(s, byName, false), nil},
+		{"before", byName, cursor(s, byName, true), []int{i2.ID, p.ID}},
+		{"before the start", byName, cursor(i, byName, true), nil},
+		{"descending", byNameDesc, cursor(p, byNameDesc, false), []int{i.ID, i2.ID}},
+		{"descending before", byNameDesc, cursor(i2, byNameDesc, true), []int{p.ID, i.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, total, err := b.Users.GetAllUsers(ctx, model.UserFilter{
+				SortBy:     tt.sort,
+				PageFilter: model.PageFilter{Page: 1, PerPage: 2, After: tt.after},
+			})
+			noErr(t, err)
+			var ids []int
+			for _, u := range users {
+				ids = append(ids, u.ID)
+			}
+			if !slices.Equal(ids, tt.want) || total != 4 {
+				t.Fatalf("expected users %v of 4, got %v of %d", tt.want, ids, total)
+			}
+		})
+	}
+
+	t1 := createTask(t, b, p.ID, "first")
+	t2 := createTask(t, b, p.ID, "second")
+	t3 := createTask(t, b, p.ID, "third")
+	createTask(t, b, i.ID, "other")
+	tasks, total, err := b.Tasks.ListUserTasks(ctx, p.ID, model.PageFilter{PerPage: 2, After: cursor(t1, model.TaskOrder, false)})
+	noErr(t, err)
+	if len(tasks) != 2 || tasks[0] != t2 || tasks[1] != t3 || total != 3 {
+		t.Fatalf("expected tasks %+v of 3, got %+v of %d", []model.Task{t2, t3}, tasks, total)
+	}
+	tasks, _, err = b.Tasks.ListUserTasks(ctx, p.ID, model.PageFilter{Page: 2, PerPage: 2})
+	noErr(t, err)
+	if len(tasks) != 1 || tasks[0] != t3 {
+		t.Fatalf("expected second page %+v, got %+v", t3, tasks)
+	}
+
+	for _, task := range []model.Task{t1, t2, t3} {
+		noErr(t, b.Tasks.StartTask(ctx, task.ID, meta))
+	}
+	entries, total, err := b.Tasks.ListUserTimeEntries(ctx, p.ID, model.PageFilter{Page: 1, PerPage: 10})
+	noErr(t, err)
+	if len(entries) != 3 || total != 3 {
+		t.Fatalf("expected 3 time entries, got %+v of %d", entries, total)
+	}
+	page, _, err := b.Tasks.ListUserTimeEntries(ctx, p.ID, model.PageFilter{PerPage: 1, After: cursor(entries[2], model.TimeEntryOrder, true)})
+	noErr(t, err)
+	if len(page) != 1 || page[0].ID != entries[1].ID {
+		t.Fatalf("expected entry %+v before %+v, got %+v", entries[1], entries[2], page)
+	}
+}
+
+func testSoftDeleteAndRestore(t *testing.T, b Backend) {
+	ctx := context.Background()
+	user := createUser(t, b, petr)
+	task := createTask(t, b, user.ID, "report")
+
+	noErr(t, b.Users.DeleteUser(ctx, user.ID, meta))
+	_, err := b.Users.GetUser(ctx, user.ID)
+	expectErr(t, err, model.ErrUserNotFound)
+	_, err = b.Tasks.GetTask(ctx, task.ID)
+	expectErr(t, err, model.ErrTaskNotFound)
+
+	deleted, err := b.Users.GetUserWithDeleted(ctx, user.ID)
+	noErr(t, err)
+	if !deleted.IsDeleted || deleted.Name != petr.Name {
+		t.Fatalf("unexpected deleted user %+v", deleted)
+	}
+
+	// A deleted user frees the passport, so restoring the old one conflicts with the new one.
+	other := createUser(t, b, petr)
+	expectErr(t, b.Users.RestoreUser(ctx, user.ID, meta), model.ErrUserAlreadyExists)
+	noErr(t, b.Users.DeleteUser(ctx, other.ID, meta))
+	noErr(t, b.Users.HardDeleteUser(ctx, other.ID, meta))
+
+	noErr(t, b.Users.RestoreUser(ctx, user.ID, meta))
+	if _, err := b.Users.GetUser(ctx, user.ID); err != nil {
+		t.Fatalf("restored user is not visible: %v", err)
+	}
+	if _, err := b.Tasks.GetTask(ctx, task.ID); err != nil {
+		t.Fatalf("task of restored user is not visible: %v", err)
+	}
+	expectErr(t, b.Users.RestoreUser(ctx, user.ID, meta), model.ErrUserNotFound)
+	expectErr(t, b.Users.DeleteUser(ctx, user.ID+100, meta), model.ErrUserNotFound)
+}
+
+func testHardDelete(t *testing.T, b Backend) {
+	ctx := context.Background()
+	user := createUser(t, b, petr)
+	task := createTask(t, b, user.ID, "report")
+	noErr(t, b.Tasks.StartTask(ctx, task.ID, meta))
+
+	// Only soft-deleted users are purged.
+	expectErr(t, b.Users.HardDeleteUser(ctx, user.ID, meta), model.ErrUserNotFound)
+	if _, err := b.Tasks.GetTask(ctx, task.ID); err != nil {
+		t.Fatalf("task of active user must be kept: %v", err)
+	}
+
+	noErr(t, b.Users.DeleteUser(ctx, user.ID, meta))
+	noErr(t, b.Users.HardDeleteUser(ctx, user.ID, meta))
+	_, err := b.Users.GetUserWithDeleted(ctx, user.ID)
+	expectErr(t, err, model.ErrUserNotFound)
+
+	tasks, err := b.Tasks.GetUserTasks(ctx, user.ID)
+	noErr(t, err)
+	entries, err := b.Tasks.GetUserTimeEntries(ctx, user.ID)
+	noErr(t, err)
+	history, err := b.Users.GetUserHistory(ctx, user.ID)
+	noErr(t, err)
+	if len(tasks) != 0 || len(entries) != 0 || len(history) != 0 {
+		t.Fatalf("expected tasks, entries and history to be deleted, got %d, %d, %d", len(tasks), len(entries), len(history))
+	}
+	running, err := b.Tasks.CountRunningTasks(ctx)
+	noErr(t, err)
+	if running != 0 {
+		t.Fatalf("expected no running tasks, got %d", running)
+	}
+
+	log, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{EntityType: ptr(model.AuditEntityUser), EntityID: ptr(user.ID), Page: 1, PerPage: 10})
+	noErr(t, err)
+	if len(log) != 3 || log[0].Action != model.AuditActionUserHardDelete {
+		t.Fatalf("unexpected audit log %+v", log)
+	}
+	for _, e := range log {
+		if len(e.Before) != 0 || len(e.After) != 0 {
+			t.Errorf("expected snapshots to be dropped from %+v", e)
+		}
+	}
+
+	expectErr(t, b.Users.HardDeleteUser(ctx, user.ID, meta), model.ErrUserNotFound)
+}
+
+func testUpdateAndHistory(t *testing.T, b Backend) {
+	ctx := context.Background()
+	before := time.Now().Add(-time.Minute)
+	user := createUser(t, b, petr)
+
+	user.Address = "ул. Новая, д. 5"
+	noErr(t, b.Users.UpdateUser(ctx, user, model.AuditMeta{Actor: "admin"}))
+	// Changing only the passport does not create a profile version.
+	user.PassportNumber = 9999
+	noErr(t, b.Users.UpdateUser(ctx, user, meta))
+
+	got, err := b.Users.GetUser(ctx, user.ID)
+	noErr(t, err)
+	if got != user {
+		t.Fatalf("expected %+v, got %+v", user, got)
+	}
+
+	history, err := b.Users.GetUserHistory(ctx, user.ID)
+	noErr(t, err)
+	if len(history) != 2 {
+		t.Fatalf("expected 2 versions, got %+v", history)
+	}
+	if history[0].Version != 1 || history[0].Address != petr.Address || history[0].ChangedBy != meta.Actor {
+		t.Errorf("unexpected first version %+v", history[0])
+	}
+	if history[1].Version != 2 || history[1].Address != user.Address || history[1].ChangedBy != "admin" {
+		t.Errorf("unexpected second version %+v", history[1])
+	}
+
+	version, err := b.Users.GetUserVersionAt(ctx, user.ID, time.Now().Add(time.Minute))
+	noErr(t, err)
+	if version.Version != 2 {
+		t.Errorf("expected latest version, got %+v", version)
+	}
+	_, err = b.Users.GetUserVersionAt(ctx, user.ID, before)
+	expectErr(t, err, model.ErrUserVersionNotFound)
+
+	other := createUser(t, b, ivan)
+	other.PassportSerie, other.PassportNumber = user.PassportSerie, user.PassportNumber
+	expectErr(t, b.Users.UpdateUser(ctx, other, meta), model.ErrUserAlreadyExists)
+	expectErr(t, b.Users.UpdateUser(ctx, model.User{ID: user.ID + 100}, meta), model.ErrUserNotFound)
+}
+
+func testEraseUser(t *testing.T, b Backend) {
+	ctx := context.Background()
+	user := createUser(t, b, petr)
+	task := createTask(t, b, user.ID, "report")
+	noErr(t, b.Tasks.StartTask(ctx, task.ID, meta))
+	stopTask(t, b, task.ID)
+
+	noErr(t, b.Users.EraseUser(ctx, user.ID, model.AuditMeta{Actor: "dpo"}))
+
+	erased, err := b.Users.GetUserWithDeleted(ctx, user.ID)
+	noErr(t, err)
+	if erased != (model.User{ID: user.ID, IsDeleted: true}) {
+		t.Fatalf("expected anonymized user, got %+v", erased)
+	}
+	history, err := b.Users.GetUserHistory(ctx, user.ID)
+	noErr(t, err)
+	if len(history) != 0 {
+		t.Fatalf("expected history to be dropped, got %+v", history)
+	}
+	entries, err := b.Tasks.GetUserTimeEntries(ctx, user.ID)
+	noErr(t, err)
+	if len(entries) != 1 {
+		t.Fatalf("expected time entries to be kept, got %+v", entries)
+	}
+
+	log, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{EntityType: ptr(model.AuditEntityUser), EntityID: ptr(user.ID), Page: 1, PerPage: 10})
+	noErr(t, err)
+	if len(log) != 2 || log[0].Action != model.AuditActionUserErase || log[0].Actor != "dpo" {
+		t.Fatalf("unexpected audit log %+v", log)
+	}
+	for _, e := range log {
+		if len(e.Before) != 0 || len(e.After) != 0 {
+			t.Errorf("expected snapshots to be dropped from %+v", e)
+		}
+	}
+
+	expectErr(t, b.Users.EraseUser(ctx, user.ID, meta), model.ErrUserNotFound)
+	expectErr(t, b.Users.RestoreUser(ctx, user.ID, meta), model.ErrUserNotFound)
+}
+
+func testTaskLifecycle(t *testing.T, b Backend) {
+	ctx := context.Background()
+	user := createUser(t, b, petr)
+
+	_, err := b.Tasks.CreateTask(ctx, model.Task{UserID: user.ID + 100, Name: "orphan"}, meta)
+	expectErr(t, err, model.ErrUserNotFound)
+
+	task := createTask(t, b, user.ID, "report")
+	got, err := b.Tasks.GetTask(ctx, task.ID)
+	noErr(t, err)
+	if got != task {
+		t.Fatalf("expected %+v, got %+v", task, got)
+	}
+	_, err = b.Tasks.GetTask(ctx, task.ID+100)
+	expectErr(t, err, model.ErrTaskNotFound)
+	expectErr(t, b.Tasks.StartTask(ctx, task.ID+100, meta), model.ErrTaskNotFound)
+
+	expectState := func(wantStarted, wantStopped bool, wantRunning int) {
+		t.Helper()
+		started, err := b.Tasks.IsTaskStarted(ctx, task.ID)
+		noErr(t, err)
+		stopped, err := b.Tasks.IsTaskStopped(ctx, task.ID)
+		noErr(t, err)
+		running, err := b.Tasks.CountRunningTasks(ctx)
+		noErr(t, err)
+		if started != wantStarted || stopped != wantStopped || running != wantRunning {
+			t.Fatalf("expected started=%v stopped=%v running=%d, got %v %v %d",
+				wantStarted, wantStopped, wantRunning, started, stopped, running)
+		}
+	}
+
+	expectState(false, false, 0)
+	noErr(t, b.Tasks.StartTask(ctx, task.ID, meta))
+	expectState(true, false, 1)
+	expectErr(t, b.Tasks.StartTask(ctx, task.ID, meta), model.ErrTaskAlreadyStarted)
+	expectState(true, false, 1)
+	stopTask(t, b, task.ID)
+	expectState(false, true, 0)
+	stopped, err := b.Tasks.StopTask(ctx, task.ID, meta)
+	noErr(t, err)
+	if stopped != 0 {
+		t.Fatalf("expected nothing to be stopped again, got %d", stopped)
+	}
+
+	entries, err := b.Tasks.GetUserTimeEntries(ctx, user.ID)
+	noErr(t, err)
+	if len(entries) != 1 || entries[0].TaskID != task.ID || entries[0].EndTime == nil || entries[0].EndTime.Before(entries[0].StartTime) {
+		t.Fatalf("unexpected time entries %+v", entries)
+	}
+	tasks, err := b.Tasks.GetUserTasks(ctx, user.ID)
+	noErr(t, err)
+	if len(tasks) != 1 || tasks[0] != task {
+		t.Fatalf("unexpected user tasks %+v", tasks)
+	}
+}
+
+func testConcurrentStart(t *testing.T, b Backend) {
+	ctx := context.Background()
+	task := createTask(t, b, createUser(t, b, petr).ID, "report")
+	tasks := service.NewTaskService(b.Tasks, b.Tx)
+
+	const starts = 8
+	errs := make(chan error, starts)
+	var wg sync.WaitGroup
+	for range starts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- tasks.StartTask(ctx, task.ID, meta)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	started := 0
+	for err := range errs {
+		if err == nil {
+			started++
+			continue
+		}
+		expectErr(t, err, model.ErrTaskAlreadyStarted)
+	}
+	running, err := b.Tasks.CountRunningTasks(ctx)
+	noErr(t, err)
+	if started != 1 || running != 1 {
+		t.Fatalf("expected exactly one start to succeed, got %d started and %d running", started, running)
+	}
+}
+
+func testStopUserTasks(t *testing.T, b Backend) {
+	ctx := context.Background()
+	p := createUser(t, b, petr)
+	i := createUser(t, b, ivan)
+	for _, task := range []model.Task{createTask(t, b, p.ID, "a"), createTask(t, b, p.ID, "b"), createTask(t, b, i.ID, "c")} {
+		noErr(t, b.Tasks.StartTask(ctx, task.ID, meta))
+	}
+
+	entries, err := b.Tasks.GetUserRunningEntries(ctx, p.ID)
+	noErr(t, err)
+	if len(entries) != 2 || entries[0].EndTime != nil || entries[1].EndTime != nil {
+		t.Fatalf("expected 2 running entries of the user, got %+v", entries)
+	}
+
+	stopped, err := b.Tasks.StopUserTasks(ctx, p.ID, meta)
+	noErr(t, err)
+	if stopped != 2 {
+		t.Fatalf("expected 2 stopped entries, got %d", stopped)
+	}
+	running, err := b.Tasks.CountRunningTasks(ctx)
+	noErr(t, err)
+	if running != 1 {
+		t.Fatalf("expected only the task of another user to keep running, got %d", running)
+	}
+	entries, err = b.Tasks.GetUserRunningEntries(ctx, p.ID)
+	noErr(t, err)
+	if len(entries) != 0 {
+		t.Fatalf("expected no running entries after stop, got %+v", entries)
+	}
+
+	log, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{EntityType: ptr(model.AuditEntityUser), EntityID: ptr(p.ID), Page: 1, PerPage: 10})
+	noErr(t, err)
+	if len(log) == 0 || log[0].Action != model.AuditActionTaskStopAll {
+		t.Fatalf("expected stop_all audit entry, got %+v", log)
+	}
+
+	// Nothing is running anymore, so no audit entry is written.
+	stopped, err = b.Tasks.StopUserTasks(ctx, p.ID, meta)
+	noErr(t, err)
+	if stopped != 0 {
+		t.Fatalf("expected nothing to be stopped, got %d", stopped)
+	}
+	again, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{EntityType: ptr(model.AuditEntityUser), EntityID: ptr(p.ID), Page: 1, PerPage: 10})
+	noErr(t, err)
+	if len(again) != len(log) {
+		t.Fatalf("expected no new audit entries, got %+v", again)
+	}
+}
+
+func testTimeSpent(t *testing.T, b Backend) {
+	ctx := context.Background()
+	user := createUser(t, b, petr)
+	done := createTask(t, b, user.ID, "done")
+	running := createTask(t, b, user.ID, "running")
+	createTask(t, b, user.ID, "idle")
+	noErr(t, b.Tasks.StartTask(ctx, done.ID, meta))
+	stopTask(t, b, done.ID)
+	noErr(t, b.Tasks.StartTask(ctx, running.ID, meta))
+
+	from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	spent, err := b.Users.GetUserTimeSpent(ctx, user.ID, from, to)
+	noErr(t, err)
+	if len(spent) != 2 {
+		t.Fatalf("expected time of finished and running tasks, got %+v", spent)
+	}
+	for _, s := range spent {
+		if (s.TaskID != done.ID && s.TaskID != running.ID) || s.TotalMinutes < 0 || s.TotalMinutes > 1 {
+			t.Errorf("unexpected time spent %+v", s)
+		}
+	}
+
+	spent, err = b.Users.GetUserTimeSpent(ctx, user.ID, to, to.Add(time.Hour))
+	noErr(t, err)
+	if len(spent) != 0 {
+		t.Fatalf("expected no time spent in the future, got %+v", spent)
+	}
+
+	noErr(t, b.Users.DeleteUser(ctx, user.ID, meta))
+	spent, err = b.Users.GetUserTimeSpent(ctx, user.ID, from, to)
+	noErr(t, err)
+	if len(spent) != 0 {
+		t.Fatalf("expected no time spent for deleted user, got %+v", spent)
+	}
+}
+
+// testTimeSpentAcrossBoundary checks that an entry crossing a boundary of the period, e.g. a timer
+// running over the start of a week, is counted up to the boundary on both sides of it.
+func testTimeSpentAcrossBoundary(t *testing.T, b Backend) {
+	ctx := context.Background()
+	user := createUser(t, b, petr)
+	task := createTask(t, b, user.ID, "overnight")
+	noErr(t, b.Tasks.StartTask(ctx, task.ID, meta))
+	time.Sleep(50 * time.Millisecond)
+	stopTask(t, b, task.ID)
+
+	entries, err := b.Tasks.GetUserTimeEntries(ctx, user.ID)
+	noErr(t, err)
+	if len(entries) != 1 || entries[0].EndTime == nil {
+		t.Fatalf("expected one finished entry, got %+v", entries)
+	}
+	start, end := entries[0].StartTime, *entries[0].EndTime
+	boundary := start.Add(end.Sub(start) / 2)
+
+	for _, c := range []struct {
+		name     string
+		from, to time.Time
+		want     time.Duration
+	}{
+		{"after boundary", boundary, end.Add(time.Hour), end.Sub(boundary)},
+		{"before boundary", start.Add(-time.Hour), boundary, boundary.Sub(start)},
+		{"inside entry", start.Add(end.Sub(start) / 4), boundary, end.Sub(start) / 4},
+	} {
+		spent, err := b.Users.GetUserTimeSpent(ctx, user.ID, c.from, c.to)
+		noErr(t, err)
+		if len(spent) != 1 || spent[0].TaskID != task.ID || math.Abs(spent[0].TotalMinutes-c.want.Minutes()) > time.Millisecond.Minutes() {
+			t.Errorf("%s: expected %v of task %d, got %+v", c.name, c.want, task.ID, spent)
+		}
+	}
+}
+
+func testSearch(t *testing.T, b Backend) {
+	ctx := context.Background()
+	p := createUser(t, b, petr)
+	i := createUser(t, b, ivan)
+	s := createUser(t, b, sidor)
+	report := createTask(t, b, p.ID, "Квартальный отчет")
+	createTask(t, b, i.ID, "Созвон")
+	hidden := createTask(t, b, s.ID, "Отчет Сидора")
+	noErr(t, b.Users.DeleteUser(ctx, s.ID, meta))
+
+	ids := func(results []model.SearchResult, typ string) []int {
+		var ids []int
+		for _, r := range results {
+			if r.Type != typ || r.Rank <= 0 {
+				t.Fatalf("unexpected result %+v", r)
+			}
+			ids = append(ids, r.ID())
+		}
+		return ids
+	}
+
+	users, err := b.Users.SearchUsers(ctx, "петров", 10)
+	noErr(t, err)
+	if got := ids(users, model.SearchTypeUser); !slices.Equal(got, []int{p.ID}) || *users[0].User != p {
+		t.Fatalf("expected user %+v, got %+v", p, users)
+	}
+	users, err = b.Users.SearchUsers(ctx, "Сидоров", 10)
+	noErr(t, err)
+	if len(users) != 0 {
+		t.Fatalf("expected deleted users to be hidden, got %+v", users)
+	}
+	users, err = b.Users.SearchUsers(ctx, "Петров Иванов", 1)
+	noErr(t, err)
+	if len(users) != 1 {
+		t.Fatalf("expected limit to be respected, got %+v", users)
+	}
+
+	tasks, err := b.Tasks.SearchTasks(ctx, "отчет", 10)
+	noErr(t, err)
+	if got := ids(tasks, model.SearchTypeTask); !slices.Equal(got, []int{report.ID}) || *tasks[0].Task != report {
+		t.Fatalf("expected task %+v without the task %+v of the deleted user, got %+v", report, hidden, tasks)
+	}
+}
+
+func testAuditLog(t *testing.T, b Backend) {
+	ctx := context.Background()
+	user := createUser(t, b, petr)
+	user.Address = "ул. Новая, д. 5"
+	noErr(t, b.Users.UpdateUser(ctx, user, model.AuditMeta{Actor: "admin"}))
+	noErr(t, b.Users.DeleteUser(ctx, user.ID, meta))
+
+	log, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{Page: 1, PerPage: 10})
+	noErr(t, err)
+	wantActions := []string{model.AuditActionUserDelete, model.AuditActionUserUpdate, model.AuditActionUserCreate}
+	if len(log) != len(wantActions) {
+		t.Fatalf("expected %d entries, got %+v", len(wantActions), log)
+	}
+	for k, action := range wantActions {
+		if log[k].Action != action || log[k].EntityID != user.ID {
+			t.Errorf("expected %s at position %d, got %+v", action, k, log[k])
+		}
+	}
+	if log[2].Before != nil || len(log[2].After) == 0 {
+		t.Errorf("expected only after snapshot on create, got %+v", log[2])
+	}
+	if log[0].RequestID == nil || *log[0].RequestID != meta.RequestID {
+		t.Errorf("expected request id, got %+v", log[0].RequestID)
+	}
+
+	byActor, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{Actor: ptr("admin"), Page: 1, PerPage: 10})
+	noErr(t, err)
+	if len(byActor) != 1 || byActor[0].Action != model.AuditActionUserUpdate {
+		t.Errorf("unexpected entries of actor %+v", byActor)
+	}
+
+	page, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{Page: 2, PerPage: 2})
+	noErr(t, err)
+	if len(page) != 1 || page[0].Action != model.AuditActionUserCreate {
+		t.Errorf("unexpected second page %+v", page)
+	}
+
+	future := time.Now().Add(time.Hour)
+	empty, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{From: &future, Page: 1, PerPage: 10})
+	noErr(t, err)
+	if empty == nil || len(empty) != 0 {
+		t.Errorf("expected empty non-nil list, got %+v", empty)
+	}
+}
+
+func testTransactions(t *testing.T, b Backend) {
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	err := b.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		user := createUserCtx(t, ctx, b, petr)
+		createTaskCtx(t, ctx, b, user.ID)
+		return errRollback
+	})
+	expectErr(t, err, errRollback)
+
+	users, _, err := b.Users.GetAllUsers(ctx, model.UserFilter{PageFilter: model.PageFilter{Page: 1, PerPage: 10}})
+	noErr(t, err)
+	log, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{Page: 1, PerPage: 10})
+	noErr(t, err)
+	if len(users) != 0 || len(log) != 0 {
+		t.Fatalf("expected rollback to discard users and audit, got %+v and %+v", users, log)
+	}
+
+	var userID int
+	noErr(t, b.Tx.WithinTx(ctx, func(ctx context.Context) error {
+		user := createUserCtx(t, ctx, b, petr)
+		userID = user.ID
+		// A nested transaction joins the outer one.
+		return b.Tx.WithinTx(ctx, func(ctx context.Context) error {
+			createTaskCtx(t, ctx, b, user.ID)
+			return nil
+		})
+	}))
+	tasks, err := b.Tasks.GetUserTasks(ctx, userID)
+	noErr(t, err)
+	if len(tasks) != 1 {
+		t.Fatalf("expected committed task, got %+v", tasks)
+	}
+}
+
+// failingStopTasks stops the tasks of the user and then fails, as if the connection was lost afterwards.
+type failingStopTasks struct {
+	repository.TaskRepoI
+}
+
+func (r failingStopTasks) StopUserTasks(ctx context.Context, userID int, meta model.AuditMeta) (int, error) {
+	if _, err := r.TaskRepoI.StopUserTasks(ctx, userID, meta); err != nil {
+		return 0, err
+	}
+	return 0, errors.New("connection reset")
+}
+
+// testServiceRollback checks that a service operation spanning several repositories
+// is rolled back by the transaction manager of the backend.
+func testServiceRollback(t *testing.T, b Backend) {
+	ctx := context.Background()
+	user := createUser(t, b, petr)
+	task := createTask(t, b, user.ID, "report")
+	noErr(t, b.Tasks.StartTask(ctx, task.ID, meta))
+
+	users := service.NewUserService(b.Users, failingStopTasks{b.Tasks}, b.Tx)
+	if err := users.DeleteUser(ctx, user.ID, meta); err == nil {
+		t.Fatal("expected DeleteUser to fail")
+	}
+
+	_, err := b.Users.GetUser(ctx, user.ID)
+	noErr(t, err)
+	started, err := b.Tasks.IsTaskStarted(ctx, task.ID)
+	noErr(t, err)
+	if !started {
+		t.Error("task must keep running after rollback")
+	}
+	log, err := b.Audit.GetAuditLog(ctx, model.AuditFilter{EntityType: ptr(model.AuditEntityUser), EntityID: ptr(user.ID), Page: 1, PerPage: 10})
+	noErr(t, err)
+	if len(log) != 1 || log[0].Action != model.AuditActionUserCreate {
+		t.Fatalf("expected audit entries of the rolled back delete to be discarded, got %+v", log)
+	}
+}
+
+func createUserCtx(t *testing.T, ctx context.Context, b Backend, user model.User) model.User {
+	t.Helper()
+	id, err := b.Users.CreateUser(ctx, user, meta)
+	noErr(t, err)
+	user.ID = id
+	return user
+}
+
+func createTaskCtx(t *testing.T, ctx context.Context, b Backend, userID int) {
+	t.Helper()
+	_, err := b.Tasks.CreateTask(ctx, model.Task{UserID: userID, Name: "in tx"}, meta)
+	noErr(t, err)
+}
+
+func testIdempotencyKeys(t *testing.T, b Backend) {
+	ctx := context.Background()
+	longAgo := time.Now().Add(-time.Hour)
+	record := model.IdempotencyRecord{Key: "key-1", Fingerprint: "create petr", Owner: "owner-1"}
+
+	stored, reserved, err := b.Idempotency.ReserveKey(ctx, record, longAgo, longAgo)
+	noErr(t, err)
+	if !reserved || stored.Key != record.Key || stored.Owner != record.Owner || stored.Completed() {
+		t.Fatalf("expected key to be reserved in progress, got %+v reserved=%v", stored, reserved)
+	}
+	stored, reserved, err = b.Idempotency.ReserveKey(ctx, model.IdempotencyRecord{Key: "key-1", Fingerprint: "other", Owner: "owner-2"}, longAgo, longAgo)
+	noErr(t, err)
+	if reserved || stored.Fingerprint != record.Fingerprint || stored.Completed() {
+		t.Fatalf("expected the in-progress record, got %+v reserved=%v", stored, reserved)
+	}
+
+	noErr(t, b.Idempotency.CompleteKey(ctx, record.Key, record.Owner, 201, []byte(`{"message":"1"}`)))
+	stored, reserved, err = b.Idempotency.ReserveKey(ctx, record, longAgo, longAgo)
+	noErr(t, err)
+	if reserved || stored.Status != 201 || string(stored.Body) != `{"message":"1"}` {
+		t.Fatalf("expected the completed record, got %+v reserved=%v", stored, reserved)
+	}
+
+	noErr(t, b.Idempotency.DeleteKey(ctx, record.Key, "owner-2"))
+	if _, reserved, err = b.Idempotency.ReserveKey(ctx, record, longAgo, longAgo); err != nil || reserved {
+		t.Fatalf("expected key to be kept when deleted by another owner, got reserved=%v, %v", reserved, err)
+	}
+	noErr(t, b.Idempotency.DeleteKey(ctx, record.Key, record.Owner))
+	_, reserved, err = b.Idempotency.ReserveKey(ctx, record, longAgo, longAgo)
+	noErr(t, err)
+	if !reserved {
+		t.Fatal("expected deleted key to be reserved again")
+	}
+
+	// Records created before the expiry time are forgotten.
+	_, reserved, err = b.Idempotency.ReserveKey(ctx, record, time.Now().Add(time.Minute), longAgo)
+	noErr(t, err)
+	if !reserved {
+		t.Fatal("expected expired key to be reserved again")
+	}
+
+	// Records in progress for longer than the lease are taken over, completed ones are kept.
+	other := model.IdempotencyRecord{Key: "key-1", Fingerprint: "other", Owner: "owner-2"}
+	stored, reserved, err = b.Idempotency.ReserveKey(ctx, other, longAgo, time.Now().Add(time.Minute))
+	noErr(t, err)
+	if !reserved || stored.Fingerprint != other.Fingerprint || stored.Owner != other.Owner || stored.Completed() {
+		t.Fatalf("expected abandoned key to be reserved again, got %+v reserved=%v", stored, reserved)
+	}
+
+	// The request which lost its reservation can neither complete nor delete the record of the new owner.
+	noErr(t, b.Idempotency.CompleteKey(ctx, record.Key, record.Owner, 201, []byte(`{"message":"1"}`)))
+	noErr(t, b.Idempotency.DeleteKey(ctx, record.Key, record.Owner))
+	stored, reserved, err = b.Idempotency.ReserveKey(ctx, record, longAgo, longAgo)
+	noErr(t, err)
+	if reserved || stored.Owner != other.Owner || stored.Completed() {
+		t.Fatalf("expected the reservation of the new owner to be kept in progress, got %+v reserved=%v", stored, reserved)
+	}
+
+	noErr(t, b.Idempotency.CompleteKey(ctx, other.Key, other.Owner, 201, []byte(`{"message":"2"}`)))
+	stored, reserved, err = b.Idempotency.ReserveKey(ctx, other, longAgo, time.Now().Add(time.Minute))
+	noErr(t, err)
+	if reserved || stored.Status != 201 {
+		t.Fatalf("expected the completed record to be kept, got %+v reserved=%v", stored, reserved)
+	}
+}
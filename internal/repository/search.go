@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"strings"
+)
+
+// Search documents of users and tasks. The expressions must stay in sync with the
+// indexes of the search_indexes migration, otherwise Postgres does not use them.
+const (
+	userDocument = `name || ' ' || surname || ' ' || coalesce(patronymic, '') || ' ' || address`
+	taskDocument = `t.name || ' ' || coalesce(t.description, '')`
+	// searchQuery matches documents containing any of the stemmed words of $1. The lexemes
+	// are cast as they are, to_tsquery would stem them again and Russian stemming is not idempotent.
+	searchQuery = `(replace(plainto_tsquery('russian', $1)::text, ' & ', ' | '))::tsquery AS query`
+)
+
+// WordRank is the relevance used by the storages without full-text search:
+// the share of the words of query found in document, ignoring case.
+func WordRank(query, document string) float64 {
+	words := strings.Fields(strings.ToLower(query))
+	if len(words) == 0 {
+		return 0
+	}
+	document = strings.ToLower(document)
+	found := 0
+	for _, w := range words {
+		if strings.Contains(document, w) {
+			found++
+		}
+	}
+	return float64(found) / float64(len(words))
+}
+
+// UserText and TaskText are the search documents for WordRank.
+func UserText(u model.User) string {
+	return strings.Join([]string{u.Name, u.Surname, u.Patronymic, u.Address}, " ")
+}
+
+func TaskText(t model.Task) string {
+	return t.Name + " " + t.Description
+}
+
+// TopResults sorts results by relevance and returns at most limit of them.
+func TopResults(results []model.SearchResult, limit int) []model.SearchResult {
+	model.SortSearchResults(results)
+	return results[:min(limit, len(results))]
+}
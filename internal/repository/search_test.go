@@ -0,0 +1,89 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/pgtest"
+	"testing"
+	"time"
+)
+
+func TestSearch(t *testing.T) {
+	db := pgtest.Open(t)
+	users := repository.NewUserRepo(db, 5*time.Second)
+	tasks := repository.NewTaskRepo(db, 5*time.Second)
+	ctx := context.Background()
+
+	ids := seedUsers(t, users,
+		model.User{PassportSerie: 1234, PassportNumber: 5678, Name: "Петр", Surname: "Петров", Patronymic: "Петрович", Address: "ул. Пушкина, д. 1"},
+		model.User{PassportSerie: 4321, PassportNumber: 8765, Name: "Иван", Surname: "Пушкин", Patronymic: "Иванович", Address: "ул. Пушкина, д. 2"},
+	)
+	report, err := tasks.CreateTask(ctx, model.Task{UserID: ids[0], Name: "Квартальные отчеты", Description: "сверка продаж"}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  []int
+	}{
+		{"stemmed word", "пушкину", []int{ids[1], ids[0]}},
+		{"more matching words rank higher", "петр пушкин", []int{ids[0], ids[1]}},
+		{"misspelled word", "Петровч", []int{ids[0]}},
+		{"no matches", "Сидоров", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := users.SearchUsers(ctx, tt.query, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, r := range results {
+				got = append(got, r.User.ID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected users %v, got %+v", tt.want, results)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected users %v, got %v", tt.want, got)
+				}
+			}
+		})
+	}
+
+	for _, query := range []string{"отчет", "продажи"} {
+		results, err := tasks.SearchTasks(ctx, query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Task.ID != report {
+			t.Errorf("expected task %d for %q, got %+v", report, query, results)
+		}
+	}
+}
+
+// Stemming "Петровой" gives петров, which stemmed again would become петр:
+// that matches the surname Петров instead of the address on Петрова street.
+func TestSearchStemsQueryOnce(t *testing.T) {
+	db := pgtest.Open(t)
+	users := repository.NewUserRepo(db, 5*time.Second)
+	ctx := context.Background()
+
+	ids := seedUsers(t, users,
+		model.User{PassportSerie: 1234, PassportNumber: 5678, Name: "Петр", Surname: "Петров", Patronymic: "Петрович", Address: "ул. Пушкина, д. 1"},
+		model.User{PassportSerie: 4321, PassportNumber: 8765, Name: "Семен", Surname: "Семенов", Patronymic: "Семенович", Address: "ул. Петрова, д. 3"},
+	)
+
+	// The second word occurs nowhere, so that the query is too far from any document to match by similarity.
+	results, err := users.SearchUsers(ctx, "Петровой зебра", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].User.ID != ids[1] {
+		t.Fatalf("expected only user %d, got %+v", ids[1], results)
+	}
+}
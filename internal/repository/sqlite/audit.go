@@ -0,0 +1,121 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"strings"
+	"time"
+)
+
+type AuditRepo struct {
+	db           *sqlx.DB
+	queryTimeout time.Duration
+}
+
+func NewAuditRepo(db *sqlx.DB, queryTimeout time.Duration) *AuditRepo {
+	return &AuditRepo{db: db, queryTimeout: queryTimeout}
+}
+
+func (r *AuditRepo) GetAuditLog(ctx context.Context, filter model.AuditFilter) (_ []model.AuditEntry, err error) {
+	ctx, finish := startQuery(ctx, "AuditRepo.GetAuditLog", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT id, actor, action, entity_type, entity_id, before, after, request_id, created_at FROM audit_log`
+
+	var conditions []string
+	var args []interface{}
+
+	if filter.EntityType != nil {
+		conditions = append(conditions, "entity_type = ?")
+		args = append(args, *filter.EntityType)
+	}
+	if filter.EntityID != nil {
+		conditions = append(conditions, "entity_id = ?")
+		args = append(args, *filter.EntityID)
+	}
+	if filter.Actor != nil {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, *filter.Actor)
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	if len(conditions) > 0 {
+		q += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	q += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)
+
+	var rows []auditRow
+	if err := repository.Conn(ctx, r.db).SelectContext(ctx, &rows, q, args...); err != nil {
+		return nil, err
+	}
+	entries := make([]model.AuditEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = row.AuditEntry
+		entries[i].Before, entries[i].After = row.Before, row.After
+	}
+	return entries, nil
+}
+
+// auditRow scans the snapshots as bytes, the driver returns NULL as untyped nil
+// which database/sql cannot store into json.RawMessage.
+type auditRow struct {
+	model.AuditEntry
+	Before []byte `db:"before"`
+	After  []byte `db:"after"`
+}
+
+// writeAudit records a mutation in the audit log within the transaction of the mutation itself.
+// before and after are stored as JSON text, nil values are stored as NULL.
+func writeAudit(ctx context.Context, tx *sqlx.Tx, meta model.AuditMeta, action, entityType string, entityID int, before, after any) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+
+	var requestID *string
+	if meta.RequestID != "" {
+		requestID = &meta.RequestID
+	}
+
+	q := `INSERT INTO audit_log (actor, action, entity_type, entity_id, before, after, request_id, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, q, meta.Actor, action, entityType, entityID, beforeJSON, afterJSON, requestID, now())
+	return err
+}
+
+// clearAuditSnapshots drops the snapshots of an entity from its audit log entries,
+// keeping the record of who did what and when.
+func clearAuditSnapshots(ctx context.Context, tx *sqlx.Tx, entityType string, entityID int) error {
+	q := `UPDATE audit_log SET before = NULL, after = NULL WHERE entity_type = ? AND entity_id = ?`
+	_, err := tx.ExecContext(ctx, q, entityType, entityID)
+	return err
+}
+
+func auditJSON(v any) (*string, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling audit value: %w", err)
+	}
+	s := string(b)
+	return &s, nil
+}
@@ -0,0 +1,68 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"time"
+)
+
+type IdempotencyRepo struct {
+	db           *sqlx.DB
+	queryTimeout time.Duration
+}
+
+func NewIdempotencyRepo(db *sqlx.DB, queryTimeout time.Duration) *IdempotencyRepo {
+	return &IdempotencyRepo{db: db, queryTimeout: queryTimeout}
+}
+
+func (r *IdempotencyRepo) ReserveKey(ctx context.Context, record model.IdempotencyRecord, expiredBefore, abandonedBefore time.Time) (_ model.IdempotencyRecord, reserved bool, err error) {
+	ctx, finish := startQuery(ctx, "IdempotencyRepo.ReserveKey", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var stored model.IdempotencyRecord
+	err = repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `DELETE FROM idempotency_keys WHERE created_at < ? OR (status = 0 AND created_at < ?)`
+		if _, err := tx.ExecContext(ctx, q, expiredBefore.UTC(), abandonedBefore.UTC()); err != nil {
+			return err
+		}
+
+		q = `INSERT INTO idempotency_keys (key, fingerprint, owner, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO NOTHING
+		RETURNING key, fingerprint, owner, status, body, created_at`
+		err := tx.GetContext(ctx, &stored, q, record.Key, record.Fingerprint, record.Owner, now())
+		if err == nil {
+			reserved = true
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		q = `SELECT key, fingerprint, owner, status, body, created_at FROM idempotency_keys WHERE key = ?`
+		return tx.GetContext(ctx, &stored, q, record.Key)
+	})
+	if err != nil {
+		return model.IdempotencyRecord{}, false, err
+	}
+	return stored, reserved, nil
+}
+
+func (r *IdempotencyRepo) CompleteKey(ctx context.Context, key, owner string, status int, body []byte) (err error) {
+	ctx, finish := startQuery(ctx, "IdempotencyRepo.CompleteKey", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `UPDATE idempotency_keys SET status = ?, body = ? WHERE key = ? AND owner = ?`
+	_, err = repository.Conn(ctx, r.db).ExecContext(ctx, q, status, body, key, owner)
+	return err
+}
+
+func (r *IdempotencyRepo) DeleteKey(ctx context.Context, key, owner string) (err error) {
+	ctx, finish := startQuery(ctx, "IdempotencyRepo.DeleteKey", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	_, err = repository.Conn(ctx, r.db).ExecContext(ctx, `DELETE FROM idempotency_keys WHERE key = ? AND owner = ?`, key, owner)
+	return err
+}
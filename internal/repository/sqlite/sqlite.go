@@ -0,0 +1,42 @@
+// Package sqlite implements the repositories on top of SQLite for offline single binary runs.
+// Timestamps are written from Go in UTC, so that they compare correctly as text.
+// Transactions are run by repository.TxManager, which works on any database/sql backend.
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"go.opentelemetry.io/otel/attribute"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+	"time"
+)
+
+var (
+	_ repository.UserRepoI        = (*UserRepo)(nil)
+	_ repository.TaskRepoI        = (*TaskRepo)(nil)
+	_ repository.AuditRepoI       = (*AuditRepo)(nil)
+	_ repository.IdempotencyRepoI = (*IdempotencyRepo)(nil)
+)
+
+var dbSystem = attribute.String("db.system", "sqlite")
+
+func startQuery(ctx context.Context, name string, timeout time.Duration) (context.Context, func(err error)) {
+	return repository.StartQuery(ctx, dbSystem, name, timeout)
+}
+
+// now returns the current time in UTC, the only zone stored in the database.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/migrate"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/repotest"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"github.com/usmonzodasomon/time-tracker/pkg/sqlite"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContract(t *testing.T) {
+	logger.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	repotest.Run(t, func(t *testing.T) repotest.Backend {
+		db, err := sqlite.GetConnection(sqlite.Config{Path: filepath.Join(t.TempDir(), "time-tracker.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = sqlite.CloseConnection(db) })
+
+		migrator, err := migrate.NewSQLite(db.DB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := migrator.Up(context.Background()); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+
+		return repotest.Backend{
+			Users:       NewUserRepo(db, 5*time.Second),
+			Tasks:       NewTaskRepo(db, 5*time.Second),
+			Audit:       NewAuditRepo(db, 5*time.Second),
+			Idempotency: NewIdempotencyRepo(db, 5*time.Second),
+			Tx:          repository.NewTxManager(db),
+		}
+	})
+}
@@ -0,0 +1,293 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"slices"
+	"time"
+)
+
+type TaskRepo struct {
+	db           *sqlx.DB
+	queryTimeout time.Duration
+}
+
+func NewTaskRepo(db *sqlx.DB, queryTimeout time.Duration) *TaskRepo {
+	return &TaskRepo{db: db, queryTimeout: queryTimeout}
+}
+
+func (r *TaskRepo) CreateTask(ctx context.Context, task model.Task, meta model.AuditMeta) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.CreateTask", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	err = repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `INSERT INTO tasks (user_id, name, description)
+		VALUES (?, ?, ?) RETURNING id`
+		if err := tx.QueryRowxContext(ctx, q, task.UserID, task.Name, task.Description).
+			Scan(&task.ID); err != nil {
+			if isForeignKeyViolation(err) {
+				return model.ErrUserNotFound
+			}
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionTaskCreate, model.AuditEntityTask, task.ID, nil, task)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return task.ID, nil
+}
+
+func (r *TaskRepo) GetTask(ctx context.Context, id int) (_ model.Task, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.GetTask", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT t.id, t.user_id, t.name, COALESCE(t.description, '') AS description FROM tasks t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.id = ? AND u.is_deleted = FALSE`
+	task := model.Task{}
+	if err := repository.Conn(ctx, r.db).GetContext(ctx, &task, q, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Task{}, model.ErrTaskNotFound
+		}
+		return model.Task{}, err
+	}
+	return task, nil
+}
+
+func (r *TaskRepo) StartTask(ctx context.Context, taskID int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.StartTask", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `INSERT INTO time_entries (task_id, start_time) VALUES (?, ?)
+		RETURNING id, task_id, start_time, end_time`
+		entry := model.TimeEntry{}
+		if err := tx.GetContext(ctx, &entry, q, taskID, now()); err != nil {
+			if isForeignKeyViolation(err) {
+				return model.ErrTaskNotFound
+			}
+			// A task has at most one running entry, enforced by a partial unique index.
+			if isUniqueViolation(err) {
+				return model.ErrTaskAlreadyStarted
+			}
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionTaskStart, model.AuditEntityTask, taskID, nil, entry)
+	})
+}
+
+func (r *TaskRepo) IsTaskStarted(ctx context.Context, taskID int) (_ bool, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.IsTaskStarted", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT EXISTS (SELECT 1 FROM time_entries WHERE task_id = ? AND end_time IS NULL)`
+	var started bool
+	if err := repository.Conn(ctx, r.db).GetContext(ctx, &started, q, taskID); err != nil {
+		return false, err
+	}
+	return started, nil
+}
+
+func (r *TaskRepo) IsTaskStopped(ctx context.Context, taskID int) (_ bool, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.IsTaskStopped", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT EXISTS (SELECT 1 FROM time_entries WHERE task_id = ? AND end_time IS NOT NULL)`
+	var stopped bool
+	if err := repository.Conn(ctx, r.db).GetContext(ctx, &stopped, q, taskID); err != nil {
+		return false, err
+	}
+	return stopped, nil
+}
+
+// StopTask stops the running time entries of the task and returns how many were stopped.
+func (r *TaskRepo) StopTask(ctx context.Context, taskID int, meta model.AuditMeta) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.StopTask", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var entries []model.TimeEntry
+	err = repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `UPDATE time_entries SET end_time = ? WHERE task_id = ? AND end_time IS NULL
+		RETURNING id, task_id, start_time, end_time`
+		if err := tx.SelectContext(ctx, &entries, q, now(), taskID); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionTaskStop, model.AuditEntityTask, taskID, runningEntries(entries), entries)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// StopUserTasks stops all running time entries of the user and returns how many were stopped.
+func (r *TaskRepo) StopUserTasks(ctx context.Context, userID int, meta model.AuditMeta) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.StopUserTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var entries []model.TimeEntry
+	err = repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `UPDATE time_entries SET end_time = ?
+		WHERE end_time IS NULL AND task_id IN (SELECT id FROM tasks WHERE user_id = ?)
+		RETURNING id, task_id, start_time, end_time`
+		if err := tx.SelectContext(ctx, &entries, q, now(), userID); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionTaskStopAll, model.AuditEntityUser, userID, runningEntries(entries), entries)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+func (r *TaskRepo) CountRunningTasks(ctx context.Context) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.CountRunningTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT COUNT(*) FROM time_entries WHERE end_time IS NULL`
+	var count int
+	if err := repository.Conn(ctx, r.db).GetContext(ctx, &count, q); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// runningEntries returns copies of stopped entries as they were before being stopped.
+func runningEntries(entries []model.TimeEntry) []model.TimeEntry {
+	running := make([]model.TimeEntry, len(entries))
+	for i, e := range entries {
+		e.EndTime = nil
+		running[i] = e
+	}
+	return running
+}
+
+func (r *TaskRepo) GetUserTasks(ctx context.Context, userID int) (_ []model.Task, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.GetUserTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT id, user_id, name, COALESCE(description, '') AS description FROM tasks WHERE user_id = ? ORDER BY id`
+	var tasks []model.Task
+	if err := repository.Conn(ctx, r.db).SelectContext(ctx, &tasks, q, userID); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *TaskRepo) GetUserTimeEntries(ctx context.Context, userID int) (_ []model.TimeEntry, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.GetUserTimeEntries", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT te.id, te.task_id, te.start_time, te.end_time FROM time_entries te
+		JOIN tasks t ON t.id = te.task_id
+		WHERE t.user_id = ? ORDER BY te.start_time`
+	var entries []model.TimeEntry
+	if err := repository.Conn(ctx, r.db).SelectContext(ctx, &entries, q, userID); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetUserRunningEntries returns the time entries of the user's tasks which are not stopped yet.
+func (r *TaskRepo) GetUserRunningEntries(ctx context.Context, userID int) (_ []model.TimeEntry, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.GetUserRunningEntries", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT te.id, te.task_id, te.start_time, te.end_time FROM time_entries te
+		JOIN tasks t ON t.id = te.task_id
+		WHERE t.user_id = ? AND te.end_time IS NULL ORDER BY te.start_time`
+	var entries []model.TimeEntry
+	if err := repository.Conn(ctx, r.db).SelectContext(ctx, &entries, q, userID); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ListUserTasks returns the page of tasks of the user and the number of all of them.
+func (r *TaskRepo) ListUserTasks(ctx context.Context, userID int, filter model.PageFilter) (_ []model.Task, _ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.ListUserTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var tasks []model.Task
+	total, err := listPage(ctx, r.db, &tasks, filter, model.TaskOrder,
+		"id, user_id, name, description", "tasks", "user_id = ?", userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+// ListUserTimeEntries returns the page of time entries of the user's tasks and the number of all of them.
+func (r *TaskRepo) ListUserTimeEntries(ctx context.Context, userID int, filter model.PageFilter) (_ []model.TimeEntry, _ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.ListUserTimeEntries", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var entries []model.TimeEntry
+	total, err := listPage(ctx, r.db, &entries, filter, model.TimeEntryOrder,
+		"id, task_id, start_time, end_time", "time_entries", "task_id IN (SELECT id FROM tasks WHERE user_id = ?)", userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// SearchTasks returns the tasks of active users most relevant to query, like UserRepo.SearchUsers.
+func (r *TaskRepo) SearchTasks(ctx context.Context, query string, limit int) (_ []model.SearchResult, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.SearchTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	condition, args := anyWord(`t.name || ' ' || coalesce(t.description, '')`, query)
+	if condition == "" {
+		return nil, nil
+	}
+	q := `SELECT t.id, t.user_id, t.name, coalesce(t.description, '') AS description FROM tasks t
+		JOIN users u ON u.id = t.user_id
+		WHERE u.is_deleted = FALSE AND ` + condition
+	var tasks []model.Task
+	if err := repository.Conn(ctx, r.db).SelectContext(ctx, &tasks, q, args...); err != nil {
+		return nil, err
+	}
+	results := make([]model.SearchResult, len(tasks))
+	for i, t := range tasks {
+		results[i] = model.SearchResult{Type: model.SearchTypeTask, Rank: repository.WordRank(query, repository.TaskText(t)), Task: &t}
+	}
+	return repository.TopResults(results, limit), nil
+}
+
+// listPage selects the page of rows of table matching condition, which uses the arguments args,
+// into dest and returns the number of all matching rows.
+func listPage[T model.Keyed](ctx context.Context, db *sqlx.DB, dest *[]T, filter model.PageFilter, order []model.SortField,
+	columns, table, condition string, args ...any) (int, error) {
+	var total int
+	if err := repository.Conn(ctx, db).GetContext(ctx, &total, "SELECT COUNT(*) FROM "+table+" WHERE "+condition, args...); err != nil {
+		return 0, err
+	}
+
+	window, err := repository.NewWindow[T](filter, order)
+	if err != nil {
+		return 0, err
+	}
+	if window.After != nil {
+		condition += " AND " + window.KeysetCondition(bind(&args))
+	}
+	q := "SELECT " + columns + " FROM " + table + " WHERE " + condition + " ORDER BY " + window.OrderBy() + " LIMIT ? OFFSET ?"
+	if err := repository.Conn(ctx, db).SelectContext(ctx, dest, q, append(args, window.Limit, window.Offset)...); err != nil {
+		return 0, err
+	}
+	if window.Reverse {
+		slices.Reverse(*dest)
+	}
+	return total, nil
+}
@@ -0,0 +1,445 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/jmoiron/sqlx"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	sqlitedriver "modernc.org/sqlite"
+	"slices"
+	"strings"
+	"time"
+)
+
+func init() {
+	// LOWER and LIKE of SQLite fold only ASCII letters, names and addresses are mostly Cyrillic.
+	err := sqlitedriver.RegisterDeterministicScalarFunction("unicode_lower", 1,
+		func(_ *sqlitedriver.FunctionContext, args []driver.Value) (driver.Value, error) {
+			s, ok := args[0].(string)
+			if !ok {
+				return args[0], nil
+			}
+			return strings.ToLower(s), nil
+		})
+	if err != nil {
+		panic(err)
+	}
+}
+
+type UserRepo struct {
+	db           *sqlx.DB
+	queryTimeout time.Duration
+}
+
+func NewUserRepo(db *sqlx.DB, queryTimeout time.Duration) *UserRepo {
+	return &UserRepo{db: db, queryTimeout: queryTimeout}
+}
+
+// GetAllUsers returns the page of users matching filter and the number of all matching users.
+func (r *UserRepo) GetAllUsers(ctx context.Context, filter model.UserFilter) (_ []model.User, _ int, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetAllUsers", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var conditions []string
+	var args []interface{}
+
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "is_deleted = FALSE")
+	}
+	if filter.ID != nil {
+		conditions = append(conditions, "id = ?")
+		args = append(args, *filter.ID)
+	}
+	if filter.PassportSerie != nil {
+		conditions = append(conditions, "passport_serie = ?")
+		args = append(args, *filter.PassportSerie)
+	}
+	if filter.PassportNumber != nil {
+		conditions = append(conditions, "passport_number = ?")
+		args = append(args, *filter.PassportNumber)
+	}
+	contains := func(column string, value *string) {
+		if value != nil {
+			conditions = append(conditions, "unicode_lower("+column+") LIKE ?")
+			args = append(args, "%"+strings.ToLower(*value)+"%")
+		}
+	}
+	contains("name", filter.Name)
+	contains("surname", filter.Surname)
+	contains("patronymic", filter.Patronymic)
+	contains("address", filter.Address)
+	if filter.Q != nil {
+		for _, word := range strings.Fields(*filter.Q) {
+			conditions = append(conditions, `(unicode_lower(name) LIKE ? OR unicode_lower(surname) LIKE ?
+				OR unicode_lower(patronymic) LIKE ? OR unicode_lower(address) LIKE ?)`)
+			pattern := "%" + strings.ToLower(word) + "%"
+			args = append(args, pattern, pattern, pattern, pattern)
+		}
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := repository.Conn(ctx, r.db).GetContext(ctx, &total, "SELECT COUNT(*) FROM users"+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	window, err := repository.NewWindow[model.User](filter.PageFilter, model.KeysetOrder(filter.SortBy))
+	if err != nil {
+		return nil, 0, err
+	}
+	if window.After != nil {
+		conditions = append(conditions, window.KeysetCondition(bind(&args)))
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address, is_deleted FROM users` + where
+	q += " ORDER BY " + window.OrderBy() + " LIMIT ? OFFSET ?"
+	args = append(args, window.Limit, window.Offset)
+
+	var users []model.User
+	if err := repository.Conn(ctx, r.db).SelectContext(ctx, &users, q, args...); err != nil {
+		return nil, 0, err
+	}
+	if window.Reverse {
+		slices.Reverse(users)
+	}
+	return users, total, nil
+}
+
+// SearchUsers returns the active users most relevant to query. Without full-text search
+// in SQLite a user matches when it contains any of the words and is ranked by repository.WordRank.
+func (r *UserRepo) SearchUsers(ctx context.Context, query string, limit int) (_ []model.SearchResult, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.SearchUsers", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	condition, args := anyWord(`name || ' ' || surname || ' ' || coalesce(patronymic, '') || ' ' || address`, query)
+	if condition == "" {
+		return nil, nil
+	}
+	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address FROM users
+		WHERE is_deleted = FALSE AND ` + condition
+	var users []model.User
+	if err := repository.Conn(ctx, r.db).SelectContext(ctx, &users, q, args...); err != nil {
+		return nil, err
+	}
+	results := make([]model.SearchResult, len(users))
+	for i, u := range users {
+		results[i] = model.SearchResult{Type: model.SearchTypeUser, Rank: repository.WordRank(query, repository.UserText(u)), User: &u}
+	}
+	return repository.TopResults(results, limit), nil
+}
+
+// anyWord returns a condition matching document containing any of the words of query, ignoring case.
+func anyWord(document, query string) (string, []any) {
+	var conditions []string
+	var args []any
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		conditions = append(conditions, "unicode_lower("+document+") LIKE ?")
+		args = append(args, "%"+word+"%")
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// bind returns a placeholder binder for repository.Window.KeysetCondition appending to args.
+func bind(args *[]any) func(any) string {
+	return func(v any) string {
+		if t, ok := v.(time.Time); ok {
+			// Timestamps are stored as UTC text, which compares in time order only in the same zone.
+			v = t.UTC()
+		}
+		*args = append(*args, v)
+		return "?"
+	}
+}
+
+func (r *UserRepo) GetUser(ctx context.Context, id int) (_ model.User, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address FROM users
+		WHERE id = ? AND is_deleted = FALSE`
+	user := model.User{}
+	if err := repository.Conn(ctx, r.db).GetContext(ctx, &user, q, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, model.ErrUserNotFound
+		}
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// GetUserByPassport returns the active user with the passport.
+func (r *UserRepo) GetUserByPassport(ctx context.Context, passportSerie, passportNumber int) (_ model.User, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserByPassport", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address FROM users
+		WHERE passport_serie = ? AND passport_number = ? AND is_deleted = FALSE`
+	user := model.User{}
+	if err := repository.Conn(ctx, r.db).GetContext(ctx, &user, q, passportSerie, passportNumber); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, model.ErrUserNotFound
+		}
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// GetUserTimeSpent sums the time entries of the user overlapping the period, counting only their part inside of it.
+func (r *UserRepo) GetUserTimeSpent(ctx context.Context, userID int, startPeriod, endPeriod time.Time) (_ []model.TaskTimeSpent, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserTimeSpent", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `
+		SELECT
+			t.id AS task_id,
+			SUM(
+				MIN(julianday(COALESCE(te.end_time, ?1)), julianday(?4)) - MAX(julianday(te.start_time), julianday(?3))
+			) * 1440 AS total_minutes
+		FROM users u
+		JOIN tasks t ON u.id = t.user_id
+		JOIN time_entries te ON t.id = te.task_id
+		WHERE
+			u.id = ?2
+			AND julianday(te.start_time) < julianday(?4)
+			AND julianday(COALESCE(te.end_time, ?1)) > julianday(?3)
+			AND u.is_deleted = FALSE
+		GROUP BY t.id
+		ORDER BY total_minutes DESC`
+	var tasks []model.TaskTimeSpent
+	if err := repository.Conn(ctx, r.db).SelectContext(ctx, &tasks, q, now(), userID, startPeriod.UTC(), endPeriod.UTC()); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *UserRepo) CreateUser(ctx context.Context, user model.User, meta model.AuditMeta) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.CreateUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	err = repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `INSERT INTO users
+		(passport_serie, passport_number, name, surname, patronymic, address)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING id`
+		if err := tx.QueryRowxContext(ctx, q, user.PassportSerie, user.PassportNumber, user.Name, user.Surname, user.Patronymic, user.Address).
+			Scan(&user.ID); err != nil {
+			if isUniqueViolation(err) {
+				return model.ErrUserAlreadyExists
+			}
+			return err
+		}
+		if err := writeUserVersion(ctx, tx, user, meta); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionUserCreate, model.AuditEntityUser, user.ID, nil, user)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+func (r *UserRepo) UpdateUser(ctx context.Context, user model.User, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.UpdateUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := getUser(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		q := `UPDATE users SET passport_serie = ?, passport_number = ?, name = ?, surname = ?, patronymic = ?, address = ? WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, q, user.PassportSerie, user.PassportNumber, user.Name, user.Surname, user.Patronymic, user.Address, user.ID); err != nil {
+			if isUniqueViolation(err) {
+				return model.ErrUserAlreadyExists
+			}
+			return err
+		}
+		if user.Name != before.Name || user.Surname != before.Surname ||
+			user.Patronymic != before.Patronymic || user.Address != before.Address {
+			if err := writeUserVersion(ctx, tx, user, meta); err != nil {
+				return err
+			}
+		}
+		user.IsDeleted = before.IsDeleted
+		return writeAudit(ctx, tx, meta, model.AuditActionUserUpdate, model.AuditEntityUser, user.ID, before, user)
+	})
+}
+
+func (r *UserRepo) DeleteUser(ctx context.Context, id int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.DeleteUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := getUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		q := `UPDATE users SET is_deleted = TRUE WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+		after := before
+		after.IsDeleted = true
+		return writeAudit(ctx, tx, meta, model.AuditActionUserDelete, model.AuditEntityUser, id, before, after)
+	})
+}
+
+func (r *UserRepo) RestoreUser(ctx context.Context, id int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.RestoreUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := getUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		q := `UPDATE users SET is_deleted = FALSE WHERE id = ? AND is_deleted = TRUE AND erased_at IS NULL`
+		res, err := tx.ExecContext(ctx, q, id)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return model.ErrUserAlreadyExists
+			}
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+			return model.ErrUserNotFound
+		}
+		after := before
+		after.IsDeleted = false
+		return writeAudit(ctx, tx, meta, model.AuditActionUserRestore, model.AuditEntityUser, id, before, after)
+	})
+}
+
+// HardDeleteUser removes the soft-deleted user with its tasks, time entries and profile history.
+// Snapshots of the user are dropped from the audit log, so no personal data is left behind.
+func (r *UserRepo) HardDeleteUser(ctx context.Context, id int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.HardDeleteUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		user, err := getUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if !user.IsDeleted {
+			return model.ErrUserNotFound
+		}
+
+		q := `DELETE FROM users WHERE id = ? AND is_deleted = TRUE`
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+		if err := clearAuditSnapshots(ctx, tx, model.AuditEntityUser, id); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionUserHardDelete, model.AuditEntityUser, id, nil, nil)
+	})
+}
+
+func (r *UserRepo) GetUserWithDeleted(ctx context.Context, id int) (_ model.User, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserWithDeleted", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return getUser(ctx, repository.Conn(ctx, r.db), id)
+}
+
+// EraseUser anonymizes personal fields of the user and records who performed the erasure.
+// Tasks and time entries are kept so that aggregated statistics stay intact,
+// while profile history and user snapshots already stored in the audit log are dropped.
+func (r *UserRepo) EraseUser(ctx context.Context, id int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.EraseUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return repository.WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		erasedAt := now()
+		q := `UPDATE users SET passport_serie = 0, passport_number = 0, name = '', surname = '', patronymic = '', address = '',
+			is_deleted = TRUE, erased_at = ? WHERE id = ? AND erased_at IS NULL`
+		res, err := tx.ExecContext(ctx, q, erasedAt, id)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+			return model.ErrUserNotFound
+		}
+
+		q = `INSERT INTO user_erasures (user_id, performed_by, performed_at) VALUES (?, ?, ?)`
+		if _, err := tx.ExecContext(ctx, q, id, meta.Actor, erasedAt); err != nil {
+			return err
+		}
+
+		q = `DELETE FROM user_versions WHERE user_id = ?`
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+
+		if err := clearAuditSnapshots(ctx, tx, model.AuditEntityUser, id); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionUserErase, model.AuditEntityUser, id, nil, nil)
+	})
+}
+
+func (r *UserRepo) GetUserHistory(ctx context.Context, userID int) (_ []model.UserVersion, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserHistory", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT user_id, version, name, surname, COALESCE(patronymic, '') AS patronymic, address, changed_by, changed_at
+		FROM user_versions WHERE user_id = ? ORDER BY version`
+	versions := []model.UserVersion{}
+	if err := repository.Conn(ctx, r.db).SelectContext(ctx, &versions, q, userID); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+func (r *UserRepo) GetUserVersionAt(ctx context.Context, userID int, at time.Time) (_ model.UserVersion, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserVersionAt", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT user_id, version, name, surname, COALESCE(patronymic, '') AS patronymic, address, changed_by, changed_at
+		FROM user_versions WHERE user_id = ? AND changed_at <= ? ORDER BY version DESC LIMIT 1`
+	version := model.UserVersion{}
+	if err := repository.Conn(ctx, r.db).GetContext(ctx, &version, q, userID, at.UTC()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.UserVersion{}, model.ErrUserVersionNotFound
+		}
+		return model.UserVersion{}, err
+	}
+	return version, nil
+}
+
+// writeUserVersion stores the current profile of the user as the next version of its history.
+func writeUserVersion(ctx context.Context, tx *sqlx.Tx, user model.User, meta model.AuditMeta) error {
+	q := `INSERT INTO user_versions (user_id, version, name, surname, patronymic, address, changed_by, changed_at)
+	SELECT ?1, COALESCE(MAX(version), 0) + 1, ?2, ?3, ?4, ?5, ?6, ?7 FROM user_versions WHERE user_id = ?1`
+	_, err := tx.ExecContext(ctx, q, user.ID, user.Name, user.Surname, user.Patronymic, user.Address, meta.Actor, now())
+	return err
+}
+
+// getUser reads the user including deleted ones. SQLite has no row locks, writers are serialized by the database.
+func getUser(ctx context.Context, q repository.Querier, id int) (model.User, error) {
+	query := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address, is_deleted FROM users
+		WHERE id = ?`
+	user := model.User{}
+	if err := q.GetContext(ctx, &user, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, model.ErrUserNotFound
+		}
+		return model.User{}, err
+	}
+	return user, nil
+}
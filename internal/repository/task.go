@@ -1,43 +1,73 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/jmoiron/sqlx"
 	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"slices"
+	"time"
 )
 
 type TaskRepoI interface {
-	CreateTask(task model.Task) (int, error)
-	GetTask(id int) (model.Task, error)
-	StartTask(taskID int) error
-	IsTaskStarted(taskID int) (bool, error)
-	IsTaskStopped(taskID int) (bool, error)
-	StopTask(id int) error
+	CreateTask(ctx context.Context, task model.Task, meta model.AuditMeta) (int, error)
+	GetTask(ctx context.Context, id int) (model.Task, error)
+	StartTask(ctx context.Context, taskID int, meta model.AuditMeta) error
+	IsTaskStarted(ctx context.Context, taskID int) (bool, error)
+	IsTaskStopped(ctx context.Context, taskID int) (bool, error)
+	StopTask(ctx context.Context, id int, meta model.AuditMeta) (int, error)
+	StopUserTasks(ctx context.Context, userID int, meta model.AuditMeta) (int, error)
+	GetUserTasks(ctx context.Context, userID int) ([]model.Task, error)
+	GetUserTimeEntries(ctx context.Context, userID int) ([]model.TimeEntry, error)
+	GetUserRunningEntries(ctx context.Context, userID int) ([]model.TimeEntry, error)
+	ListUserTasks(ctx context.Context, userID int, filter model.PageFilter) ([]model.Task, int, error)
+	ListUserTimeEntries(ctx context.Context, userID int, filter model.PageFilter) ([]model.TimeEntry, int, error)
+	SearchTasks(ctx context.Context, query string, limit int) ([]model.SearchResult, error)
+	CountRunningTasks(ctx context.Context) (int, error)
 }
 
 type TaskRepo struct {
-	db *sqlx.DB
+	db           *sqlx.DB
+	queryTimeout time.Duration
 }
 
-func NewTaskRepo(db *sqlx.DB) *TaskRepo {
-	return &TaskRepo{db: db}
+func NewTaskRepo(db *sqlx.DB, queryTimeout time.Duration) *TaskRepo {
+	return &TaskRepo{db: db, queryTimeout: queryTimeout}
 }
 
-func (r *TaskRepo) CreateTask(task model.Task) (int, error) {
-	q := `INSERT INTO tasks (user_id, name, description)
-	VALUES ($1, $2, $3) RETURNING id`
-	if err := r.db.QueryRowx(q, task.UserID, task.Name, task.Description).
-		Scan(&task.ID); err != nil {
+func (r *TaskRepo) CreateTask(ctx context.Context, task model.Task, meta model.AuditMeta) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.CreateTask", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	err = WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `INSERT INTO tasks (user_id, name, description)
+		VALUES ($1, $2, $3) RETURNING id`
+		if err := tx.QueryRowxContext(ctx, q, task.UserID, task.Name, task.Description).
+			Scan(&task.ID); err != nil {
+			if isForeignKeyViolation(err) {
+				return model.ErrUserNotFound
+			}
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionTaskCreate, model.AuditEntityTask, task.ID, nil, task)
+	})
+	if err != nil {
 		return 0, err
 	}
 	return task.ID, nil
 }
 
-func (r *TaskRepo) GetTask(id int) (model.Task, error) {
-	q := `SELECT id, user_id, name, description FROM tasks WHERE id = $1`
+func (r *TaskRepo) GetTask(ctx context.Context, id int) (_ model.Task, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.GetTask", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT t.id, t.user_id, t.name, t.description FROM tasks t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.id = $1 AND u.is_deleted = false`
 	task := model.Task{}
-	if err := r.db.Get(&task, q, id); err != nil {
+	if err := Conn(ctx, r.db).GetContext(ctx, &task, q, id); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return model.Task{}, model.ErrTaskNotFound
 		}
@@ -46,16 +76,35 @@ func (r *TaskRepo) GetTask(id int) (model.Task, error) {
 	return task, nil
 }
 
-func (r *TaskRepo) StartTask(taskID int) error {
-	q := `INSERT INTO time_entries (task_id, start_time) VALUES ($1, NOW())`
-	_, err := r.db.Exec(q, taskID)
-	return err
+func (r *TaskRepo) StartTask(ctx context.Context, taskID int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.StartTask", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `INSERT INTO time_entries (task_id, start_time) VALUES ($1, NOW())
+		RETURNING id, task_id, start_time, end_time`
+		entry := model.TimeEntry{}
+		if err := tx.GetContext(ctx, &entry, q, taskID); err != nil {
+			if isForeignKeyViolation(err) {
+				return model.ErrTaskNotFound
+			}
+			// A task has at most one running entry, enforced by a partial unique index.
+			if isUniqueViolation(err) {
+				return model.ErrTaskAlreadyStarted
+			}
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionTaskStart, model.AuditEntityTask, taskID, nil, entry)
+	})
 }
 
-func (r *TaskRepo) IsTaskStarted(taskID int) (bool, error) {
+func (r *TaskRepo) IsTaskStarted(ctx context.Context, taskID int) (_ bool, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.IsTaskStarted", r.queryTimeout)
+	defer func() { finish(err) }()
+
 	q := `SELECT id FROM time_entries WHERE task_id = $1 AND end_time IS NULL`
 	var id int
-	if err := r.db.Get(&id, q, taskID); err != nil {
+	if err := Conn(ctx, r.db).GetContext(ctx, &id, q, taskID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, nil
 		}
@@ -64,10 +113,13 @@ func (r *TaskRepo) IsTaskStarted(taskID int) (bool, error) {
 	return true, nil
 }
 
-func (r *TaskRepo) IsTaskStopped(taskID int) (bool, error) {
+func (r *TaskRepo) IsTaskStopped(ctx context.Context, taskID int) (_ bool, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.IsTaskStopped", r.queryTimeout)
+	defer func() { finish(err) }()
+
 	q := `SELECT id FROM time_entries WHERE task_id = $1 AND end_time IS NOT NULL`
 	var id int
-	if err := r.db.Get(&id, q, taskID); err != nil {
+	if err := Conn(ctx, r.db).GetContext(ctx, &id, q, taskID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false, nil
 		}
@@ -76,11 +128,195 @@ func (r *TaskRepo) IsTaskStopped(taskID int) (bool, error) {
 	return true, nil
 }
 
-func (r *TaskRepo) StopTask(taskID int) error {
-	q := `UPDATE time_entries SET end_time = NOW() WHERE task_id = $1 AND end_time IS NULL`
-	_, err := r.db.Exec(q, taskID)
+// StopTask stops the running time entries of the task and returns how many were stopped.
+func (r *TaskRepo) StopTask(ctx context.Context, taskID int, meta model.AuditMeta) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.StopTask", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var entries []model.TimeEntry
+	err = WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `UPDATE time_entries SET end_time = NOW() WHERE task_id = $1 AND end_time IS NULL
+		RETURNING id, task_id, start_time, end_time`
+		if err := tx.SelectContext(ctx, &entries, q, taskID); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionTaskStop, model.AuditEntityTask, taskID, runningEntries(entries), entries)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// StopUserTasks stops all running time entries of the user and returns how many were stopped.
+func (r *TaskRepo) StopUserTasks(ctx context.Context, userID int, meta model.AuditMeta) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.StopUserTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var entries []model.TimeEntry
+	err = WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `UPDATE time_entries SET end_time = NOW()
+		WHERE end_time IS NULL AND task_id IN (SELECT id FROM tasks WHERE user_id = $1)
+		RETURNING id, task_id, start_time, end_time`
+		if err := tx.SelectContext(ctx, &entries, q, userID); err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionTaskStopAll, model.AuditEntityUser, userID, runningEntries(entries), entries)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+func (r *TaskRepo) CountRunningTasks(ctx context.Context) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.CountRunningTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT COUNT(*) FROM time_entries WHERE end_time IS NULL`
+	var count int
+	if err := Conn(ctx, r.db).GetContext(ctx, &count, q); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// runningEntries returns copies of stopped entries as they were before being stopped.
+func runningEntries(entries []model.TimeEntry) []model.TimeEntry {
+	running := make([]model.TimeEntry, len(entries))
+	for i, e := range entries {
+		e.EndTime = nil
+		running[i] = e
+	}
+	return running
+}
+
+func (r *TaskRepo) GetUserTasks(ctx context.Context, userID int) (_ []model.Task, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.GetUserTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT id, user_id, name, description FROM tasks WHERE user_id = $1 ORDER BY id`
+	var tasks []model.Task
+	if err := Conn(ctx, r.db).SelectContext(ctx, &tasks, q, userID); err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (r *TaskRepo) GetUserTimeEntries(ctx context.Context, userID int) (_ []model.TimeEntry, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.GetUserTimeEntries", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT te.id, te.task_id, te.start_time, te.end_time FROM time_entries te
+		JOIN tasks t ON t.id = te.task_id
+		WHERE t.user_id = $1 ORDER BY te.start_time`
+	var entries []model.TimeEntry
+	if err := Conn(ctx, r.db).SelectContext(ctx, &entries, q, userID); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetUserRunningEntries returns the time entries of the user's tasks which are not stopped yet.
+func (r *TaskRepo) GetUserRunningEntries(ctx context.Context, userID int) (_ []model.TimeEntry, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.GetUserRunningEntries", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT te.id, te.task_id, te.start_time, te.end_time FROM time_entries te
+		JOIN tasks t ON t.id = te.task_id
+		WHERE t.user_id = $1 AND te.end_time IS NULL ORDER BY te.start_time`
+	var entries []model.TimeEntry
+	if err := Conn(ctx, r.db).SelectContext(ctx, &entries, q, userID); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ListUserTasks returns the page of tasks of the user and the number of all of them.
+func (r *TaskRepo) ListUserTasks(ctx context.Context, userID int, filter model.PageFilter) (_ []model.Task, _ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.ListUserTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var tasks []model.Task
+	total, err := listPage(ctx, r.db, &tasks, filter, model.TaskOrder,
+		"id, user_id, name, description", "tasks", "user_id = $1", userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+// ListUserTimeEntries returns the page of time entries of the user's tasks and the number of all of them.
+func (r *TaskRepo) ListUserTimeEntries(ctx context.Context, userID int, filter model.PageFilter) (_ []model.TimeEntry, _ int, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.ListUserTimeEntries", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	var entries []model.TimeEntry
+	total, err := listPage(ctx, r.db, &entries, filter, model.TimeEntryOrder,
+		"id, task_id, start_time, end_time", "time_entries", "task_id IN (SELECT id FROM tasks WHERE user_id = $1)", userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// SearchTasks returns the tasks of active users most relevant to query, like UserRepo.SearchUsers.
+func (r *TaskRepo) SearchTasks(ctx context.Context, query string, limit int) (_ []model.SearchResult, err error) {
+	ctx, finish := startQuery(ctx, "TaskRepo.SearchTasks", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT t.id, t.user_id, t.name, coalesce(t.description, '') AS description,
+		ts_rank(to_tsvector('russian', ` + taskDocument + `), query) + word_similarity($1, ` + taskDocument + `) AS rank
+		FROM tasks t JOIN users u ON u.id = t.user_id, ` + searchQuery + `
+		WHERE u.is_deleted = false
+		AND (to_tsvector('russian', ` + taskDocument + `) @@ query OR (` + taskDocument + `) %> $1)
+		ORDER BY rank DESC, t.id LIMIT $2`
+	var hits []struct {
+		model.Task
+		Rank float64 `db:"rank"`
+	}
+	if err := Conn(ctx, r.db).SelectContext(ctx, &hits, q, query, limit); err != nil {
+		return nil, err
+	}
+	results := make([]model.SearchResult, len(hits))
+	for i, h := range hits {
+		results[i] = model.SearchResult{Type: model.SearchTypeTask, Rank: h.Rank, Task: &h.Task}
+	}
+	return results, nil
+}
+
+// listPage selects the page of rows of table matching condition, which uses the arguments args,
+// into dest and returns the number of all matching rows.
+func listPage[T model.Keyed](ctx context.Context, db *sqlx.DB, dest *[]T, filter model.PageFilter, order []model.SortField,
+	columns, table, condition string, args ...any) (int, error) {
+	var total int
+	if err := Conn(ctx, db).GetContext(ctx, &total, "SELECT COUNT(*) FROM "+table+" WHERE "+condition, args...); err != nil {
+		return 0, err
+	}
+
+	window, err := NewWindow[T](filter, order)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if window.After != nil {
+		condition += " AND " + window.KeysetCondition(func(v any) string {
+			args = append(args, v)
+			return fmt.Sprintf("$%d", len(args))
+		})
+	}
+	q := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY %s LIMIT $%d OFFSET $%d",
+		columns, table, condition, window.OrderBy(), len(args)+1, len(args)+2)
+	if err := Conn(ctx, db).SelectContext(ctx, dest, q, append(args, window.Limit, window.Offset)...); err != nil {
+		return 0, err
+	}
+	if window.Reverse {
+		slices.Reverse(*dest)
 	}
-	return nil
+	return total, nil
 }
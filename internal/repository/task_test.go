@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/pgtest"
+	"testing"
+	"time"
+)
+
+func TestTaskRepo_Queries(t *testing.T) {
+	db := pgtest.Open(t)
+	users := repository.NewUserRepo(db, 5*time.Second)
+	tasks := repository.NewTaskRepo(db, 5*time.Second)
+	ctx := context.Background()
+
+	ids := seedUsers(t, users, model.User{PassportSerie: 1234, PassportNumber: 5678, Name: "Петр", Surname: "Петров", Address: "ул. Петрова, д. 1"})
+	first, err := tasks.CreateTask(ctx, model.Task{UserID: ids[0], Name: "first", Description: "описание"}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := tasks.CreateTask(ctx, model.Task{UserID: ids[0], Name: "second"}, meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tasks.CreateTask(ctx, model.Task{UserID: ids[0] + 1, Name: "orphan"}, meta); !errors.Is(err, model.ErrUserNotFound) {
+		t.Errorf("expected ErrUserNotFound for missing user, got %v", err)
+	}
+	if err := tasks.StartTask(ctx, second+1, meta); !errors.Is(err, model.ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound for missing task, got %v", err)
+	}
+
+	task, err := tasks.GetTask(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task != (model.Task{ID: first, UserID: ids[0], Name: "first", Description: "описание"}) {
+		t.Errorf("unexpected task %+v", task)
+	}
+
+	day := time.Date(2024, 7, 1, 9, 0, 0, 0, time.Local)
+	seedEntry(t, db, second, day.Add(time.Hour), day.Add(2*time.Hour))
+	seedEntry(t, db, first, day, day.Add(time.Hour))
+	if err := tasks.StartTask(ctx, first, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := tasks.GetUserTimeEntries(ctx, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[0].TaskID != first || entries[1].TaskID != second || entries[2].EndTime != nil {
+		t.Fatalf("expected entries ordered by start time with the running one last, got %+v", entries)
+	}
+	if !entries[0].StartTime.Equal(day) || !entries[0].EndTime.Equal(day.Add(time.Hour)) {
+		t.Errorf("expected exact bounds of the seeded entry, got %+v", entries[0])
+	}
+
+	userTasks, err := tasks.GetUserTasks(ctx, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userTasks) != 2 || userTasks[0].ID != first || userTasks[1].ID != second {
+		t.Errorf("expected tasks ordered by id, got %+v", userTasks)
+	}
+
+	running, err := tasks.CountRunningTasks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, err := tasks.IsTaskStarted(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if running != 1 || !started {
+		t.Errorf("expected one running task, got %d running and started=%v", running, started)
+	}
+
+	if stopped, err := tasks.StopTask(ctx, first, meta); err != nil || stopped != 1 {
+		t.Fatalf("expected one entry to be stopped, got %d, %v", stopped, err)
+	}
+	// Stopping without a running entry changes nothing and is not audited.
+	if stopped, err := tasks.StopTask(ctx, first, meta); err != nil || stopped != 0 {
+		t.Fatalf("expected nothing to be stopped, got %d, %v", stopped, err)
+	}
+	var stops int
+	if err := db.Get(&stops, `SELECT COUNT(*) FROM audit_log WHERE action = $1`, model.AuditActionTaskStop); err != nil {
+		t.Fatal(err)
+	}
+	if stops != 1 {
+		t.Errorf("expected one stop audit entry, got %d", stops)
+	}
+}
@@ -1,40 +1,57 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
 	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"slices"
 	"strings"
 	"time"
 )
 
 type UserRepoI interface {
-	GetAllUsers(filter model.UserFilter) ([]model.User, error)
-	GetUserTimeSpent(userID int, startPeriod, endPeriod time.Time) ([]model.TaskTimeSpent, error)
+	GetAllUsers(ctx context.Context, filter model.UserFilter) ([]model.User, int, error)
+	GetUserTimeSpent(ctx context.Context, userID int, startPeriod, endPeriod time.Time) ([]model.TaskTimeSpent, error)
 
-	GetUser(id int) (model.User, error)
-	CreateUser(user model.User) (int, error)
-	UpdateUser(user model.User) error
-	DeleteUser(id int) error
+	GetUser(ctx context.Context, id int) (model.User, error)
+	GetUserByPassport(ctx context.Context, passportSerie, passportNumber int) (model.User, error)
+	CreateUser(ctx context.Context, user model.User, meta model.AuditMeta) (int, error)
+	UpdateUser(ctx context.Context, user model.User, meta model.AuditMeta) error
+	DeleteUser(ctx context.Context, id int, meta model.AuditMeta) error
+	RestoreUser(ctx context.Context, id int, meta model.AuditMeta) error
+	HardDeleteUser(ctx context.Context, id int, meta model.AuditMeta) error
+	GetUserWithDeleted(ctx context.Context, id int) (model.User, error)
+	EraseUser(ctx context.Context, id int, meta model.AuditMeta) error
+	GetUserHistory(ctx context.Context, userID int) ([]model.UserVersion, error)
+	GetUserVersionAt(ctx context.Context, userID int, at time.Time) (model.UserVersion, error)
+	SearchUsers(ctx context.Context, query string, limit int) ([]model.SearchResult, error)
 }
 
 type UserRepo struct {
-	db *sqlx.DB
+	db           *sqlx.DB
+	queryTimeout time.Duration
 }
 
-func NewUserRepo(db *sqlx.DB) *UserRepo {
-	return &UserRepo{db: db}
+func NewUserRepo(db *sqlx.DB, queryTimeout time.Duration) *UserRepo {
+	return &UserRepo{db: db, queryTimeout: queryTimeout}
 }
 
-func (r *UserRepo) GetAllUsers(filter model.UserFilter) ([]model.User, error) {
-	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address FROM users WHERE is_deleted = false`
+// GetAllUsers returns the page of users matching filter and the number of all matching users.
+func (r *UserRepo) GetAllUsers(ctx context.Context, filter model.UserFilter) (_ []model.User, _ int, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetAllUsers", r.queryTimeout)
+	defer func() { finish(err) }()
 
 	var conditions []string
 	var args []interface{}
 	argId := 1
 
+	if !filter.IncludeDeleted {
+		conditions = append(conditions, "is_deleted = false")
+	}
 	if filter.ID != nil {
 		conditions = append(conditions, fmt.Sprintf("id = $%d", argId))
 		args = append(args, *filter.ID)
@@ -70,26 +87,86 @@ func (r *UserRepo) GetAllUsers(filter model.UserFilter) ([]model.User, error) {
 		args = append(args, "%"+*filter.Address+"%")
 		argId++
 	}
+	if filter.Q != nil {
+		for _, word := range strings.Fields(*filter.Q) {
+			conditions = append(conditions, fmt.Sprintf(
+				"(name ILIKE $%[1]d OR surname ILIKE $%[1]d OR patronymic ILIKE $%[1]d OR address ILIKE $%[1]d)", argId))
+			args = append(args, "%"+word+"%")
+			argId++
+		}
+	}
 
+	where := ""
 	if len(conditions) > 0 {
-		q += " AND " + strings.Join(conditions, " AND ")
+		where = " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	q += fmt.Sprintf(" ORDER BY id LIMIT $%d OFFSET $%d", argId, argId+1)
-	args = append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)
+	var total int
+	if err := Conn(ctx, r.db).GetContext(ctx, &total, "SELECT COUNT(*) FROM users"+where, args...); err != nil {
+		return nil, 0, err
+	}
+
+	window, err := NewWindow[model.User](filter.PageFilter, model.KeysetOrder(filter.SortBy))
+	if err != nil {
+		return nil, 0, err
+	}
+	if window.After != nil {
+		conditions = append(conditions, window.KeysetCondition(func(v any) string {
+			args = append(args, v)
+			argId++
+			return fmt.Sprintf("$%d", argId-1)
+		}))
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address, is_deleted FROM users` + where
+	q += fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", window.OrderBy(), argId, argId+1)
+	args = append(args, window.Limit, window.Offset)
 
 	var users []model.User
-	if err := r.db.Select(&users, q, args...); err != nil {
+	if err := Conn(ctx, r.db).SelectContext(ctx, &users, q, args...); err != nil {
+		return nil, 0, err
+	}
+	if window.Reverse {
+		slices.Reverse(users)
+	}
+	return users, total, nil
+}
+
+// SearchUsers returns the active users most relevant to query. Words are matched with
+// Russian stemming, word similarity adds relevance and also finds misspelled words.
+func (r *UserRepo) SearchUsers(ctx context.Context, query string, limit int) (_ []model.SearchResult, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.SearchUsers", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address,
+		ts_rank(to_tsvector('russian', ` + userDocument + `), query) + word_similarity($1, ` + userDocument + `) AS rank
+		FROM users, ` + searchQuery + `
+		WHERE is_deleted = false
+		AND (to_tsvector('russian', ` + userDocument + `) @@ query OR (` + userDocument + `) %> $1)
+		ORDER BY rank DESC, id LIMIT $2`
+	var hits []struct {
+		model.User
+		Rank float64 `db:"rank"`
+	}
+	if err := Conn(ctx, r.db).SelectContext(ctx, &hits, q, query, limit); err != nil {
 		return nil, err
 	}
-	return users, nil
+	results := make([]model.SearchResult, len(hits))
+	for i, h := range hits {
+		results[i] = model.SearchResult{Type: model.SearchTypeUser, Rank: h.Rank, User: &h.User}
+	}
+	return results, nil
 }
 
-func (r *UserRepo) GetUser(id int) (model.User, error) {
+func (r *UserRepo) GetUser(ctx context.Context, id int) (_ model.User, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
 	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address FROM users 
     	WHERE id = $1 AND is_deleted = false`
 	user := model.User{}
-	if err := r.db.Get(&user, q, id); err != nil {
+	if err := Conn(ctx, r.db).GetContext(ctx, &user, q, id); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return model.User{}, model.ErrUserNotFound
 		}
@@ -98,21 +175,45 @@ func (r *UserRepo) GetUser(id int) (model.User, error) {
 	return user, nil
 }
 
-func (r *UserRepo) GetUserTimeSpent(userID int, startPeriod, endPeriod time.Time) ([]model.TaskTimeSpent, error) {
+// GetUserByPassport returns the active user with the passport.
+func (r *UserRepo) GetUserByPassport(ctx context.Context, passportSerie, passportNumber int) (_ model.User, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserByPassport", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address FROM users
+		WHERE passport_serie = $1 AND passport_number = $2 AND is_deleted = false`
+	user := model.User{}
+	if err := Conn(ctx, r.db).GetContext(ctx, &user, q, passportSerie, passportNumber); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, model.ErrUserNotFound
+		}
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+// GetUserTimeSpent sums the time entries of the user overlapping the period, counting only their part
+// inside of it, so that an entry crossing a boundary of the period is split between the periods around it.
+func (r *UserRepo) GetUserTimeSpent(ctx context.Context, userID int, startPeriod, endPeriod time.Time) (_ []model.TaskTimeSpent, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserTimeSpent", r.queryTimeout)
+	defer func() { finish(err) }()
+
 	q := `
         SELECT 
             t.id AS task_id, 
-            SUM(EXTRACT(EPOCH FROM (COALESCE(te.end_time, CURRENT_TIMESTAMP) - te.start_time))) / 60 AS total_minutes
+            SUM(EXTRACT(EPOCH FROM (
+                LEAST(COALESCE(te.end_time, LOCALTIMESTAMP), $3::timestamp) - GREATEST(te.start_time, $2::timestamp)
+            ))) / 60 AS total_minutes
         FROM 
             users u
         JOIN 
             tasks t ON u.id = t.user_id
-        LEFT JOIN 
+        JOIN 
             time_entries te ON t.id = te.task_id
         WHERE 
             u.id = $1
-            AND te.start_time >= $2
-            AND (te.end_time <= $3 OR te.end_time IS NULL)
+            AND te.start_time < $3::timestamp
+            AND COALESCE(te.end_time, LOCALTIMESTAMP) > $2::timestamp
             AND u.is_deleted = false
         GROUP BY 
             t.id
@@ -120,43 +221,247 @@ func (r *UserRepo) GetUserTimeSpent(userID int, startPeriod, endPeriod time.Time
             total_minutes DESC;
     `
 	var tasks []model.TaskTimeSpent
-	if err := r.db.Select(&tasks, q, userID, startPeriod, endPeriod); err != nil {
+	if err := Conn(ctx, r.db).SelectContext(ctx, &tasks, q, userID, startPeriod.UTC(), endPeriod.UTC()); err != nil {
 		return nil, err
 	}
 	return tasks, nil
 }
 
-func (r *UserRepo) CreateUser(user model.User) (int, error) {
-	q := `INSERT INTO users
-    (passport_serie, passport_number, name, surname, patronymic, address)
-	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
-	if err := r.db.QueryRowx(q, user.PassportSerie, user.PassportNumber, user.Name, user.Surname, user.Patronymic, user.Address).
-		Scan(&user.ID); err != nil {
+func (r *UserRepo) CreateUser(ctx context.Context, user model.User, meta model.AuditMeta) (_ int, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.CreateUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	err = WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `INSERT INTO users
+		(passport_serie, passport_number, name, surname, patronymic, address)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+		if err := tx.QueryRowxContext(ctx, q, user.PassportSerie, user.PassportNumber, user.Name, user.Surname, user.Patronymic, user.Address).
+			Scan(&user.ID); err != nil {
+			if isUniqueViolation(err) {
+				return model.ErrUserAlreadyExists
+			}
+			return err
+		}
+		if err := writeUserVersion(ctx, tx, user, meta); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionUserCreate, model.AuditEntityUser, user.ID, nil, user)
+	})
+	if err != nil {
 		return 0, err
 	}
 	return user.ID, nil
 }
 
-func (r *UserRepo) UpdateUser(user model.User) error {
-	q := `UPDATE users SET passport_serie = $1, passport_number = $2, name = $3, surname = $4, patronymic = $5, address = $6 WHERE id = $7`
-	res, err := r.db.Exec(q, user.PassportSerie, user.PassportNumber, user.Name, user.Surname, user.Patronymic, user.Address, user.ID)
-	if err != nil {
-		return err
+func (r *UserRepo) UpdateUser(ctx context.Context, user model.User, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.UpdateUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := getUserForUpdate(ctx, tx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		q := `UPDATE users SET passport_serie = $1, passport_number = $2, name = $3, surname = $4, patronymic = $5, address = $6 WHERE id = $7`
+		if _, err := tx.ExecContext(ctx, q, user.PassportSerie, user.PassportNumber, user.Name, user.Surname, user.Patronymic, user.Address, user.ID); err != nil {
+			if isUniqueViolation(err) {
+				return model.ErrUserAlreadyExists
+			}
+			return err
+		}
+		if user.Name != before.Name || user.Surname != before.Surname ||
+			user.Patronymic != before.Patronymic || user.Address != before.Address {
+			if err := writeUserVersion(ctx, tx, user, meta); err != nil {
+				return err
+			}
+		}
+		user.IsDeleted = before.IsDeleted
+		return writeAudit(ctx, tx, meta, model.AuditActionUserUpdate, model.AuditEntityUser, user.ID, before, user)
+	})
+}
+
+func (r *UserRepo) DeleteUser(ctx context.Context, id int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.DeleteUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := getUserForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		q := `UPDATE users SET is_deleted = true WHERE id = $1`
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+		after := before
+		after.IsDeleted = true
+		return writeAudit(ctx, tx, meta, model.AuditActionUserDelete, model.AuditEntityUser, id, before, after)
+	})
+}
+
+func (r *UserRepo) RestoreUser(ctx context.Context, id int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.RestoreUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		before, err := getUserForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		q := `UPDATE users SET is_deleted = false WHERE id = $1 AND is_deleted = true AND erased_at IS NULL`
+		res, err := tx.ExecContext(ctx, q, id)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return model.ErrUserAlreadyExists
+			}
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+			return model.ErrUserNotFound
+		}
+		after := before
+		after.IsDeleted = false
+		return writeAudit(ctx, tx, meta, model.AuditActionUserRestore, model.AuditEntityUser, id, before, after)
+	})
+}
+
+// HardDeleteUser removes the soft-deleted user with its tasks, time entries and profile history.
+// Snapshots of the user are dropped from the audit log, so no personal data is left behind.
+// Active users are not found, they have to be deleted first.
+func (r *UserRepo) HardDeleteUser(ctx context.Context, id int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.HardDeleteUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		user, err := getUserForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		if !user.IsDeleted {
+			return model.ErrUserNotFound
+		}
+
+		q := `DELETE FROM users WHERE id = $1 AND is_deleted = true`
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+		if err := clearAuditSnapshots(ctx, tx, model.AuditEntityUser, id); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionUserHardDelete, model.AuditEntityUser, id, nil, nil)
+	})
+}
+
+func (r *UserRepo) GetUserWithDeleted(ctx context.Context, id int) (_ model.User, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserWithDeleted", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address, is_deleted FROM users
+		WHERE id = $1`
+	user := model.User{}
+	if err := Conn(ctx, r.db).GetContext(ctx, &user, q, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, model.ErrUserNotFound
+		}
+		return model.User{}, err
 	}
-	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
-		return model.ErrUserNotFound
+	return user, nil
+}
+
+// EraseUser anonymizes personal fields of the user and records who performed the erasure.
+// Tasks and time entries are kept so that aggregated statistics stay intact,
+// while profile history and user snapshots already stored in the audit log are dropped.
+func (r *UserRepo) EraseUser(ctx context.Context, id int, meta model.AuditMeta) (err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.EraseUser", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	return WithTx(ctx, r.db, func(tx *sqlx.Tx) error {
+		q := `UPDATE users SET passport_serie = 0, passport_number = 0, name = '', surname = '', patronymic = '', address = '',
+			is_deleted = true, erased_at = NOW() WHERE id = $1 AND erased_at IS NULL`
+		res, err := tx.ExecContext(ctx, q, id)
+		if err != nil {
+			return err
+		}
+		if rows, err := res.RowsAffected(); err != nil || rows == 0 {
+			return model.ErrUserNotFound
+		}
+
+		q = `INSERT INTO user_erasures (user_id, performed_by) VALUES ($1, $2)`
+		if _, err := tx.ExecContext(ctx, q, id, meta.Actor); err != nil {
+			return err
+		}
+
+		q = `DELETE FROM user_versions WHERE user_id = $1`
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+
+		if err := clearAuditSnapshots(ctx, tx, model.AuditEntityUser, id); err != nil {
+			return err
+		}
+		return writeAudit(ctx, tx, meta, model.AuditActionUserErase, model.AuditEntityUser, id, nil, nil)
+	})
+}
+
+func (r *UserRepo) GetUserHistory(ctx context.Context, userID int) (_ []model.UserVersion, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserHistory", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT user_id, version, name, surname, COALESCE(patronymic, '') AS patronymic, address, changed_by, changed_at
+		FROM user_versions WHERE user_id = $1 ORDER BY version`
+	versions := []model.UserVersion{}
+	if err := Conn(ctx, r.db).SelectContext(ctx, &versions, q, userID); err != nil {
+		return nil, err
 	}
-	return nil
+	return versions, nil
 }
 
-func (r *UserRepo) DeleteUser(taskID int) error {
-	q := `UPDATE users SET is_deleted = true WHERE id = $1`
-	res, err := r.db.Exec(q, taskID)
-	if err != nil {
-		return err
+func (r *UserRepo) GetUserVersionAt(ctx context.Context, userID int, at time.Time) (_ model.UserVersion, err error) {
+	ctx, finish := startQuery(ctx, "UserRepo.GetUserVersionAt", r.queryTimeout)
+	defer func() { finish(err) }()
+
+	q := `SELECT user_id, version, name, surname, COALESCE(patronymic, '') AS patronymic, address, changed_by, changed_at
+		FROM user_versions WHERE user_id = $1 AND changed_at <= $2 ORDER BY version DESC LIMIT 1`
+	version := model.UserVersion{}
+	if err := Conn(ctx, r.db).GetContext(ctx, &version, q, userID, at.UTC()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.UserVersion{}, model.ErrUserVersionNotFound
+		}
+		return model.UserVersion{}, err
 	}
-	if rows, err := res.RowsAffected(); err != nil || rows == 0 {
-		return model.ErrUserNotFound
+	return version, nil
+}
+
+// writeUserVersion stores the current profile of the user as the next version of its history.
+func writeUserVersion(ctx context.Context, tx *sqlx.Tx, user model.User, meta model.AuditMeta) error {
+	q := `INSERT INTO user_versions (user_id, version, name, surname, patronymic, address, changed_by)
+	SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6 FROM user_versions WHERE user_id = $1`
+	_, err := tx.ExecContext(ctx, q, user.ID, user.Name, user.Surname, user.Patronymic, user.Address, meta.Actor)
+	return err
+}
+
+func getUserForUpdate(ctx context.Context, tx *sqlx.Tx, id int) (model.User, error) {
+	q := `SELECT id, passport_serie, passport_number, name, surname, patronymic, address, is_deleted FROM users
+		WHERE id = $1 FOR UPDATE`
+	user := model.User{}
+	if err := tx.GetContext(ctx, &user, q, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, model.ErrUserNotFound
+		}
+		return model.User{}, err
 	}
-	return nil
+	return user, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
 }
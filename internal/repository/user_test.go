@@ -0,0 +1,152 @@
+package repository_test
+
+import (
+	"context"
+	"github.com/jmoiron/sqlx"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/pgtest"
+	"math"
+	"testing"
+	"time"
+)
+
+var meta = model.AuditMeta{Actor: "tester"}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func seedUsers(t *testing.T, repo *repository.UserRepo, users ...model.User) []int {
+	t.Helper()
+	ids := make([]int, len(users))
+	for i, u := range users {
+		id, err := repo.CreateUser(context.Background(), u, meta)
+		if err != nil {
+			t.Fatalf("CreateUser: %v", err)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// seedEntry inserts a time entry with exact bounds, end may be zero for a running entry.
+// Bounds are stored in the session zone, UTC, like the repositories store them.
+func seedEntry(t *testing.T, db *sqlx.DB, taskID int, start, end time.Time) {
+	t.Helper()
+	var endTime *time.Time
+	if !end.IsZero() {
+		end = end.UTC()
+		endTime = &end
+	}
+	if _, err := db.Exec(`INSERT INTO time_entries (task_id, start_time, end_time) VALUES ($1, $2, $3)`, taskID, start.UTC(), endTime); err != nil {
+		t.Fatalf("insert time entry: %v", err)
+	}
+}
+
+func TestUserRepo_GetAllUsersFilters(t *testing.T) {
+	db := pgtest.Open(t)
+	repo := repository.NewUserRepo(db, 5*time.Second)
+	ctx := context.Background()
+
+	ids := seedUsers(t, repo,
+		model.User{PassportSerie: 1234, PassportNumber: 5678, Name: "Петр", Surname: "Петров", Patronymic: "Петрович", Address: "ул. Петрова, д. 1"},
+		model.User{PassportSerie: 1234, PassportNumber: 1111, Name: "Анна", Surname: "Петрова", Patronymic: "Ивановна", Address: "пр. Мира, д. 7"},
+		model.User{PassportSerie: 4321, PassportNumber: 8765, Name: "Иван", Surname: "Иванов", Patronymic: "Иванович", Address: "ул. Иванова, д. 2"},
+		model.User{PassportSerie: 9999, PassportNumber: 9999, Name: "Удален", Surname: "Петров", Patronymic: "", Address: "ул. Петрова, д. 3"},
+	)
+	if err := repo.DeleteUser(ctx, ids[3], meta); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter model.UserFilter
+		want   []int
+	}{
+		{"no filters", model.UserFilter{}, ids[:3]},
+		{"id", model.UserFilter{ID: ptr(ids[1])}, ids[1:2]},
+		{"passport serie", model.UserFilter{PassportSerie: ptr(1234)}, ids[:2]},
+		{"passport number", model.UserFilter{PassportNumber: ptr(8765)}, ids[2:3]},
+		{"name ignores case", model.UserFilter{Name: ptr("пЕТР")}, ids[:1]},
+		{"surname substring", model.UserFilter{Surname: ptr("петров")}, ids[:2]},
+		{"patronymic substring", model.UserFilter{Patronymic: ptr("иванов")}, ids[1:3]},
+		{"address substring", model.UserFilter{Address: ptr("Мира")}, ids[1:2]},
+		{"combined filters", model.UserFilter{PassportSerie: ptr(1234), Patronymic: ptr("Петрович")}, ids[:1]},
+		{"deleted user is hidden", model.UserFilter{PassportSerie: ptr(9999)}, nil},
+		{"first page", model.UserFilter{PageFilter: model.PageFilter{Page: 1, PerPage: 2}}, ids[:2]},
+		{"last page", model.UserFilter{PageFilter: model.PageFilter{Page: 2, PerPage: 2}}, ids[2:3]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.filter.Page == 0 {
+				tt.filter.Page, tt.filter.PerPage = 1, 10
+			}
+			users, _, err := repo.GetAllUsers(ctx, tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(users) != len(tt.want) {
+				t.Fatalf("expected %d users, got %+v", len(tt.want), users)
+			}
+			for i, u := range users {
+				if u.ID != tt.want[i] {
+					t.Errorf("expected user %d at %d, got %d", tt.want[i], i, u.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestUserRepo_GetUserTimeSpent(t *testing.T) {
+	db := pgtest.Open(t)
+	users := repository.NewUserRepo(db, 5*time.Second)
+	tasks := repository.NewTaskRepo(db, 5*time.Second)
+	ctx := context.Background()
+
+	ids := seedUsers(t, users,
+		model.User{PassportSerie: 1234, PassportNumber: 5678, Name: "Петр", Surname: "Петров", Address: "ул. Петрова, д. 1"},
+		model.User{PassportSerie: 4321, PassportNumber: 8765, Name: "Иван", Surname: "Иванов", Address: "ул. Иванова, д. 2"},
+	)
+	newTask := func(userID int, name string) int {
+		id, err := tasks.CreateTask(ctx, model.Task{UserID: userID, Name: name}, meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	short, long, running, other := newTask(ids[0], "short"), newTask(ids[0], "long"), newTask(ids[0], "running"), newTask(ids[1], "other")
+
+	day := time.Date(2024, 7, 1, 9, 0, 0, 0, time.Local)
+	seedEntry(t, db, short, day, day.Add(30*time.Minute))
+	seedEntry(t, db, short, day.Add(time.Hour), day.Add(time.Hour+15*time.Minute))
+	seedEntry(t, db, long, day.Add(2*time.Hour), day.Add(5*time.Hour))
+	// Entries outside of the period are not counted, entries crossing its end count up to the end.
+	seedEntry(t, db, long, day.AddDate(0, 0, -1), day.AddDate(0, 0, -1).Add(time.Hour))
+	seedEntry(t, db, long, day.Add(7*time.Hour), day.AddDate(0, 0, 1).Add(time.Hour))
+	seedEntry(t, db, running, time.Now().Add(-10*time.Minute), time.Time{})
+	seedEntry(t, db, other, day, day.Add(time.Hour))
+
+	spent, err := users.GetUserTimeSpent(ctx, ids[0], day, day.Add(12*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.TaskTimeSpent{{TaskID: long, TotalMinutes: 180 + 300}, {TaskID: short, TotalMinutes: 45}}
+	if len(spent) != len(want) {
+		t.Fatalf("expected %+v, got %+v", want, spent)
+	}
+	for i := range want {
+		if spent[i].TaskID != want[i].TaskID || math.Abs(spent[i].TotalMinutes-want[i].TotalMinutes) > 1e-6 {
+			t.Errorf("expected %+v at %d, got %+v", want[i], i, spent[i])
+		}
+	}
+
+	// A running entry counts until now.
+	spent, err = users.GetUserTimeSpent(ctx, ids[0], time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(spent) != 1 || spent[0].TaskID != running || spent[0].TotalMinutes < 9.9 || spent[0].TotalMinutes > 11 {
+		t.Errorf("expected about 10 minutes of the running task, got %+v", spent)
+	}
+}
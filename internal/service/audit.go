@@ -0,0 +1,23 @@
+package service
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+)
+
+type AuditServiceI interface {
+	GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error)
+}
+
+type AuditService struct {
+	repo repository.AuditRepoI
+}
+
+func NewAuditService(repo repository.AuditRepoI) *AuditService {
+	return &AuditService{repo: repo}
+}
+
+func (s *AuditService) GetAuditLog(ctx context.Context, filter model.AuditFilter) ([]model.AuditEntry, error) {
+	return s.repo.GetAuditLog(ctx, filter)
+}
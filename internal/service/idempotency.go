@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"time"
+)
+
+type IdempotencyServiceI interface {
+	Begin(ctx context.Context, key, fingerprint string) (model.IdempotencyRecord, error)
+	Complete(ctx context.Context, reservation model.IdempotencyRecord, status int, body []byte) error
+	Release(ctx context.Context, reservation model.IdempotencyRecord) error
+}
+
+type IdempotencyService struct {
+	repo  repository.IdempotencyRepoI
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// NewIdempotencyService returns a service remembering responses for ttl. A key reserved by a request
+// which has not completed within lease, e.g. because the process crashed, is given to the next request.
+func NewIdempotencyService(repo repository.IdempotencyRepoI, ttl, lease time.Duration) *IdempotencyService {
+	return &IdempotencyService{repo: repo, ttl: ttl, lease: lease}
+}
+
+// Begin reserves key for the request identified by fingerprint and returns the reservation, which is
+// passed on to Complete or Release. When the request was already made with key, the returned record
+// is completed and holds its response. Another request made with key fails with model.ErrIdempotencyMismatch,
+// and the same request still in progress with model.ErrIdempotencyConflict.
+func (s *IdempotencyService) Begin(ctx context.Context, key, fingerprint string) (model.IdempotencyRecord, error) {
+	owner, err := randomID()
+	if err != nil {
+		return model.IdempotencyRecord{}, err
+	}
+	now := time.Now()
+	record, reserved, err := s.repo.ReserveKey(ctx, model.IdempotencyRecord{Key: key, Fingerprint: fingerprint, Owner: owner}, now.Add(-s.ttl), now.Add(-s.lease))
+	switch {
+	case err != nil:
+		return model.IdempotencyRecord{}, err
+	case reserved:
+		return record, nil
+	case record.Fingerprint != fingerprint:
+		return model.IdempotencyRecord{}, model.ErrIdempotencyMismatch
+	case !record.Completed():
+		return model.IdempotencyRecord{}, model.ErrIdempotencyConflict
+	}
+	return record, nil
+}
+
+// Complete stores the response of the request which made the reservation. Nothing is stored when
+// the reservation outlived the lease and was taken over by another request.
+func (s *IdempotencyService) Complete(ctx context.Context, reservation model.IdempotencyRecord, status int, body []byte) error {
+	return s.repo.CompleteKey(ctx, reservation.Key, reservation.Owner, status, body)
+}
+
+// Release forgets the reservation, so that a retry of the request is handled again.
+func (s *IdempotencyService) Release(ctx context.Context, reservation model.IdempotencyRecord) error {
+	return s.repo.DeleteKey(ctx, reservation.Key, reservation.Owner)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/memory"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyService_Begin(t *testing.T) {
+	ctx := context.Background()
+	s := NewIdempotencyService(memory.NewIdempotencyRepo(memory.NewStore()), time.Hour, time.Minute)
+
+	reservation, err := s.Begin(ctx, "key", "create petr")
+	if err != nil || reservation.Completed() {
+		t.Fatalf("expected new key to be reserved, got %+v, %v", reservation, err)
+	}
+	if _, err := s.Begin(ctx, "key", "create petr"); !errors.Is(err, model.ErrIdempotencyConflict) {
+		t.Fatalf("expected request in progress, got %v", err)
+	}
+	if _, err := s.Begin(ctx, "key", "create ivan"); !errors.Is(err, model.ErrIdempotencyMismatch) {
+		t.Fatalf("expected key reused for another request, got %v", err)
+	}
+
+	if err := s.Complete(ctx, reservation, 201, []byte(`{"message":"1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	record, err := s.Begin(ctx, "key", "create petr")
+	if err != nil || record.Status != 201 || string(record.Body) != `{"message":"1"}` {
+		t.Fatalf("expected stored response, got %+v, %v", record, err)
+	}
+
+	if err := s.Release(ctx, reservation); err != nil {
+		t.Fatal(err)
+	}
+	if record, err := s.Begin(ctx, "key", "create ivan"); err != nil || record.Completed() {
+		t.Fatalf("expected released key to be reserved again, got %+v, %v", record, err)
+	}
+}
+
+func TestIdempotencyService_TakenOverReservation(t *testing.T) {
+	ctx := context.Background()
+	s := NewIdempotencyService(memory.NewIdempotencyRepo(memory.NewStore()), time.Hour, 10*time.Millisecond)
+
+	abandoned, err := s.Begin(ctx, "key", "create petr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	reservation, err := s.Begin(ctx, "key", "create petr")
+	if err != nil || reservation.Completed() {
+		t.Fatalf("expected abandoned key to be taken over, got %+v, %v", reservation, err)
+	}
+
+	// The request which outlived the lease must not touch the reservation of the new one.
+	if err := s.Complete(ctx, abandoned, 201, []byte(`{"message":"1"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Release(ctx, abandoned); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Complete(ctx, reservation, 201, []byte(`{"message":"2"}`)); err != nil {
+		t.Fatal(err)
+	}
+	record, err := s.Begin(ctx, "key", "create petr")
+	if err != nil || string(record.Body) != `{"message":"2"}` {
+		t.Fatalf("expected response of the new owner, got %+v, %v", record, err)
+	}
+}
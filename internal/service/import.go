@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/usmonzodasomon/time-tracker/internal/external_api"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"sync"
+)
+
+type ImportServiceI interface {
+	ImportUsers(ctx context.Context, rows []model.ImportRow, meta model.AuditMeta) (model.ImportReport, error)
+}
+
+type ImportService struct {
+	repo        repository.UserRepoI
+	externalAPI external_api.UserExternalInfoI
+	tx          repository.TxManagerI
+	concurrency int
+	batchSize   int
+}
+
+// NewImportService returns a service resolving at most concurrency passports through
+// the external API at a time and creating users in transactions of batchSize users.
+func NewImportService(repo repository.UserRepoI, externalAPI external_api.UserExternalInfoI, tx repository.TxManagerI,
+	concurrency, batchSize int) *ImportService {
+	return &ImportService{repo: repo, externalAPI: externalAPI, tx: tx, concurrency: concurrency, batchSize: batchSize}
+}
+
+// ImportUsers creates a user for every passport of rows and reports the result of each row.
+// Only failures of the storage as a whole are returned as errors.
+func (s *ImportService) ImportUsers(ctx context.Context, rows []model.ImportRow, meta model.AuditMeta) (model.ImportReport, error) {
+	results := make([]model.ImportResult, len(rows))
+	users := make([]model.User, len(rows))
+	seen := map[[2]int]bool{}
+	var lookups []int
+	for i, row := range rows {
+		results[i] = model.ImportResult{Line: row.Line, Passport: row.Passport}
+		serie, number, err := model.ParsePassport(row.Passport)
+		if err != nil {
+			results[i].Status, results[i].Error = model.ImportStatusInvalid, err.Error()
+			continue
+		}
+		if seen[[2]int{serie, number}] {
+			results[i].Status, results[i].Error = model.ImportStatusDuplicate, "passport repeats an earlier row"
+			continue
+		}
+		seen[[2]int{serie, number}] = true
+		users[i] = model.User{PassportSerie: serie, PassportNumber: number}
+		lookups = append(lookups, i)
+	}
+
+	s.lookup(ctx, lookups, users, results)
+	if err := ctx.Err(); err != nil {
+		return model.ImportReport{}, err
+	}
+
+	var resolved []int
+	for _, i := range lookups {
+		if results[i].Status == "" {
+			resolved = append(resolved, i)
+		}
+	}
+	for start := 0; start < len(resolved); start += s.batchSize {
+		batch := resolved[start:min(start+s.batchSize, len(resolved))]
+		if err := s.createBatch(ctx, batch, users, results, meta); err != nil {
+			return model.ImportReport{}, err
+		}
+	}
+
+	var report model.ImportReport
+	for _, r := range results {
+		report.Add(r)
+	}
+	return report, nil
+}
+
+// lookup replaces users at indexes with their data from the external API, running at most
+// s.concurrency requests at a time. Failed lookups are marked in results.
+func (s *ImportService) lookup(ctx context.Context, indexes []int, users []model.User, results []model.ImportResult) {
+	sem := make(chan struct{}, s.concurrency)
+	var wg sync.WaitGroup
+	for _, i := range indexes {
+		if ctx.Err() != nil {
+			break
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			user, err := s.externalAPI.GetUser(ctx, users[i].PassportSerie, users[i].PassportNumber)
+			if err != nil {
+				results[i].Status, results[i].Error = model.ImportStatusLookupFailed, err.Error()
+				return
+			}
+			users[i] = user
+		}()
+	}
+	wg.Wait()
+}
+
+// createBatch creates the users at indexes in one transaction. When it fails, e.g. because
+// one of the passports is already taken, the users are created one by one to report each row.
+func (s *ImportService) createBatch(ctx context.Context, indexes []int, users []model.User, results []model.ImportResult, meta model.AuditMeta) error {
+	ids := make([]int, len(indexes))
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		for k, i := range indexes {
+			id, err := s.repo.CreateUser(ctx, users[i], meta)
+			if err != nil {
+				return err
+			}
+			ids[k] = id
+		}
+		return nil
+	})
+	if err == nil {
+		for k, i := range indexes {
+			results[i].Status, results[i].UserID = model.ImportStatusCreated, ids[k]
+		}
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	for _, i := range indexes {
+		id, err := s.repo.CreateUser(ctx, users[i], meta)
+		switch {
+		case err == nil:
+			results[i].Status, results[i].UserID = model.ImportStatusCreated, id
+		case errors.Is(err, model.ErrUserAlreadyExists):
+			results[i].Status, results[i].Error = model.ImportStatusDuplicate, err.Error()
+		case ctx.Err() != nil:
+			return ctx.Err()
+		default:
+			results[i].Status, results[i].Error = model.ImportStatusFailed, err.Error()
+		}
+	}
+	return nil
+}
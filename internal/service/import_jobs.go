@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+type ImportJobsI interface {
+	StartImport(ctx context.Context, rows []model.ImportRow, meta model.AuditMeta) (model.ImportJob, error)
+	GetImportJob(ctx context.Context, id string) (model.ImportJob, error)
+}
+
+type queuedImport struct {
+	id        string
+	requestID string
+	rows      []model.ImportRow
+	meta      model.AuditMeta
+}
+
+// ImportJobs runs imports in the background, so a request only has to upload the file
+// and can poll for the report instead of waiting for every passport to be resolved.
+// Jobs are kept in the memory of the process and are lost on restart.
+type ImportJobs struct {
+	importer ImportServiceI
+	queue    chan queuedImport
+	ttl      time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*model.ImportJob
+}
+
+// NewImportJobs returns jobs running imports through importer one at a time. At most queueSize
+// imports wait for their turn and finished jobs are kept for ttl.
+func NewImportJobs(importer ImportServiceI, queueSize int, ttl time.Duration) *ImportJobs {
+	return &ImportJobs{
+		importer: importer,
+		queue:    make(chan queuedImport, queueSize),
+		ttl:      ttl,
+		jobs:     map[string]*model.ImportJob{},
+	}
+}
+
+// StartImport queues the rows for import and returns the queued job.
+// model.ErrImportQueueFull is returned when too many imports are waiting.
+func (j *ImportJobs) StartImport(ctx context.Context, rows []model.ImportRow, meta model.AuditMeta) (model.ImportJob, error) {
+	id, err := randomID()
+	if err != nil {
+		return model.ImportJob{}, err
+	}
+	job := &model.ImportJob{ID: id, Status: model.ImportJobQueued, Rows: len(rows), CreatedAt: time.Now().UTC()}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.prune(job.CreatedAt)
+	select {
+	case j.queue <- queuedImport{id: id, requestID: logger.RequestID(ctx), rows: rows, meta: meta}:
+	default:
+		return model.ImportJob{}, model.ErrImportQueueFull
+	}
+	j.jobs[id] = job
+	logger.Logger.InfoContext(ctx, "import queued", slog.String("job_id", id), slog.Int("rows", len(rows)))
+	return *job, nil
+}
+
+func (j *ImportJobs) GetImportJob(ctx context.Context, id string) (model.ImportJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return model.ImportJob{}, model.ErrImportJobNotFound
+	}
+	return *job, nil
+}
+
+// Run imports the queued jobs until ctx is cancelled. The running import is interrupted
+// and it and the jobs still waiting in the queue are marked as failed.
+func (j *ImportJobs) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case queued := <-j.queue:
+					j.finish(queued.id, nil, ctx.Err())
+				default:
+					return
+				}
+			}
+		case queued := <-j.queue:
+			j.run(ctx, queued)
+		}
+	}
+}
+
+func (j *ImportJobs) run(ctx context.Context, queued queuedImport) {
+	j.mu.Lock()
+	if job, ok := j.jobs[queued.id]; ok {
+		job.Status = model.ImportJobRunning
+	}
+	j.mu.Unlock()
+
+	// Log lines of the job carry the ID of the request which queued it.
+	ctx = logger.WithRequestID(ctx, queued.requestID)
+	log := logger.Logger.With(slog.String("job_id", queued.id))
+	report, err := j.importer.ImportUsers(ctx, queued.rows, queued.meta)
+	if err != nil {
+		log.ErrorContext(ctx, "import failed", slog.String("error", err.Error()))
+	} else {
+		log.InfoContext(ctx, "users imported",
+			slog.Int("created", report.Created),
+			slog.Int("duplicate", report.Duplicate),
+			slog.Int("lookup_failed", report.LookupFailed),
+		)
+	}
+	j.finish(queued.id, &report, err)
+}
+
+// finish records the result of the job. Errors are reported without details,
+// as they may come from the storage.
+func (j *ImportJobs) finish(id string, report *model.ImportReport, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return
+	}
+	finishedAt := time.Now().UTC()
+	job.FinishedAt = &finishedAt
+	if err != nil {
+		job.Status, job.Error = model.ImportJobFailed, "import was interrupted or failed, retry it"
+		return
+	}
+	job.Status, job.Report = model.ImportJobDone, report
+}
+
+// prune drops jobs finished more than ttl before now. It must be called with j.mu held.
+func (j *ImportJobs) prune(now time.Time) {
+	for id, job := range j.jobs {
+		if job.FinishedAt != nil && now.Sub(*job.FinishedAt) > j.ttl {
+			delete(j.jobs, id)
+		}
+	}
+}
+
+// randomID returns 32 random hex digits, e.g. to identify import jobs.
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
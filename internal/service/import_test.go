@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/usmonzodasomon/time-tracker/internal/external_api/mocks"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/pkg/logger"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingExternalInfo records the largest number of lookups running at the same time.
+type countingExternalInfo struct {
+	mocks *mocks.UserExternalInfo
+
+	mu            sync.Mutex
+	running, peak int
+}
+
+func (e *countingExternalInfo) GetUser(ctx context.Context, passportSerie, passportNumber int) (model.User, error) {
+	e.mu.Lock()
+	e.running++
+	e.peak = max(e.peak, e.running)
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.running--
+		e.mu.Unlock()
+	}()
+
+	time.Sleep(5 * time.Millisecond)
+	return e.mocks.GetUser(ctx, passportSerie, passportNumber)
+}
+
+func TestImportService_ImportUsers(t *testing.T) {
+	repos := newTestRepos()
+	repos.createUser(t, model.User{PassportSerie: 1111, PassportNumber: 2222})
+	externalAPI := &countingExternalInfo{mocks: mocks.NewUserExternalInfo()}
+	svc := NewImportService(repos.users, externalAPI, repos.tx, 2, 2)
+
+	rows := []model.ImportRow{
+		{Line: 1, Passport: "1234 5678"},
+		{Line: 2, Passport: "1111 2222"},
+		{Line: 3, Passport: "1234 5678"},
+		{Line: 4, Passport: "9999 9999"},
+		{Line: 5, Passport: "abc"},
+		{Line: 6, Passport: "4321 8765"},
+		{Line: 7, Passport: "3333 4444"},
+	}
+	report, err := svc.ImportUsers(context.Background(), rows, model.AuditMeta{Actor: "test"})
+	if err != nil {
+		t.Fatalf("ImportUsers: %v", err)
+	}
+
+	want := []string{
+		model.ImportStatusCreated,
+		model.ImportStatusDuplicate,
+		model.ImportStatusDuplicate,
+		model.ImportStatusLookupFailed,
+		model.ImportStatusInvalid,
+		model.ImportStatusCreated,
+		model.ImportStatusCreated,
+	}
+	if len(report.Results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), report.Results)
+	}
+	for i, r := range report.Results {
+		if r.Line != rows[i].Line || r.Status != want[i] {
+			t.Errorf("row %d: expected %s, got %+v", rows[i].Line, want[i], r)
+		}
+	}
+	if report.Created != 3 || report.Duplicate != 2 || report.LookupFailed != 1 || report.Invalid != 1 {
+		t.Errorf("unexpected counters %+v", report)
+	}
+	if u, err := repos.users.GetUser(context.Background(), report.Results[0].UserID); err != nil || u.Name != "Петр" {
+		t.Errorf("user was not filled from external api: %+v, %v", u, err)
+	}
+	if n := repos.countUsers(t); n != 4 {
+		t.Errorf("expected 3 users to be created, got %d users", n)
+	}
+	if repos.tx.rollbacks != 1 || repos.tx.commits != 1 {
+		t.Errorf("expected the batch with the duplicate to be rolled back, got %d commits and %d rollbacks", repos.tx.commits, repos.tx.rollbacks)
+	}
+	if externalAPI.peak > 2 {
+		t.Errorf("expected at most 2 concurrent lookups, got %d", externalAPI.peak)
+	}
+}
+
+func TestImportService_ImportUsersCancelled(t *testing.T) {
+	repos := newTestRepos()
+	svc := NewImportService(repos.users, mocks.NewUserExternalInfo(), repos.tx, 1, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := svc.ImportUsers(ctx, []model.ImportRow{{Line: 1, Passport: "1234 5678"}}, model.AuditMeta{}); err == nil {
+		t.Fatal("expected cancelled import to fail")
+	}
+	if repos.countUsers(t) != 0 {
+		t.Error("cancelled import must not create users")
+	}
+}
+
+// blockingImporter imports nothing until it is released or its context is cancelled.
+type blockingImporter struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (i *blockingImporter) ImportUsers(ctx context.Context, rows []model.ImportRow, meta model.AuditMeta) (model.ImportReport, error) {
+	i.started <- struct{}{}
+	select {
+	case <-i.release:
+		return model.ImportReport{Created: len(rows)}, nil
+	case <-ctx.Done():
+		return model.ImportReport{}, ctx.Err()
+	}
+}
+
+func TestImportJobs(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	importer := &blockingImporter{started: make(chan struct{}), release: make(chan struct{})}
+	jobs := NewImportJobs(importer, 1, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		jobs.Run(ctx)
+	}()
+	rows := []model.ImportRow{{Line: 1, Passport: "1234 5678"}}
+
+	first, err := jobs.StartImport(context.Background(), rows, model.AuditMeta{})
+	if err != nil || first.Status != model.ImportJobQueued {
+		t.Fatalf("StartImport: %+v, %v", first, err)
+	}
+	<-importer.started
+	if job, _ := jobs.GetImportJob(context.Background(), first.ID); job.Status != model.ImportJobRunning {
+		t.Errorf("expected first job to be running, got %+v", job)
+	}
+	second, err := jobs.StartImport(context.Background(), rows, model.AuditMeta{})
+	if err != nil {
+		t.Fatalf("StartImport: %v", err)
+	}
+	if _, err := jobs.StartImport(context.Background(), rows, model.AuditMeta{}); !errors.Is(err, model.ErrImportQueueFull) {
+		t.Fatalf("expected ErrImportQueueFull, got %v", err)
+	}
+
+	importer.release <- struct{}{}
+	<-importer.started
+	job, err := jobs.GetImportJob(context.Background(), first.ID)
+	if err != nil || job.Status != model.ImportJobDone || job.Report == nil || job.Report.Created != 1 || job.FinishedAt == nil {
+		t.Errorf("expected first job to be done, got %+v, %v", job, err)
+	}
+
+	cancel()
+	<-stopped
+	if job, _ := jobs.GetImportJob(context.Background(), second.ID); job.Status != model.ImportJobFailed || job.Report != nil {
+		t.Errorf("expected interrupted job to fail, got %+v", job)
+	}
+	if _, err := jobs.GetImportJob(context.Background(), "unknown"); !errors.Is(err, model.ErrImportJobNotFound) {
+		t.Errorf("expected ErrImportJobNotFound, got %v", err)
+	}
+}
+
+func TestImportJobs_ExpiresFinishedJobs(t *testing.T) {
+	logger.InitLogger(logger.Config{})
+	jobs := NewImportJobs(&blockingImporter{}, 2, time.Minute)
+	old, err := jobs.StartImport(context.Background(), nil, model.AuditMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs.finish(old.ID, &model.ImportReport{}, nil)
+	finishedAt := time.Now().Add(-2 * time.Minute)
+	jobs.jobs[old.ID].FinishedAt = &finishedAt
+
+	if _, err := jobs.StartImport(context.Background(), nil, model.AuditMeta{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jobs.GetImportJob(context.Background(), old.ID); !errors.Is(err, model.ErrImportJobNotFound) {
+		t.Errorf("expected expired job to be dropped, got %v", err)
+	}
+}
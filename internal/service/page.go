@@ -0,0 +1,58 @@
+package service
+
+import "github.com/usmonzodasomon/time-tracker/internal/model"
+
+// listPage reads a page of a listing ordered by order with list and sets the cursors of the
+// neighbouring pages. With a cursor one more row than requested is read to find out whether
+// the listing goes on in that direction.
+func listPage[T model.Keyed](filter model.PageFilter, order []model.SortField, sort string,
+	list func(filter model.PageFilter) ([]T, int, error)) (model.Page[T], error) {
+	if filter.After == nil {
+		items, total, err := list(filter)
+		if err != nil {
+			return model.Page[T]{}, err
+		}
+		page := model.Page[T]{Items: items, Total: total}
+		if len(items) > 0 {
+			if filter.Page > 1 {
+				page.PrevCursor = model.NewCursor(items[0], order, true, sort)
+			}
+			if (filter.Page-1)*filter.PerPage+len(items) < total {
+				page.NextCursor = model.NewCursor(items[len(items)-1], order, false, sort)
+			}
+		}
+		return page, nil
+	}
+
+	perPage, before := filter.PerPage, filter.After.Before
+	filter.PerPage++
+	items, total, err := list(filter)
+	if err != nil {
+		return model.Page[T]{}, err
+	}
+	more := len(items) > perPage
+	if more && before {
+		items = items[1:]
+	} else if more {
+		items = items[:perPage]
+	}
+
+	page := model.Page[T]{Items: items, Total: total}
+	if len(items) == 0 {
+		// The way back is the other side of the cursor row.
+		back := model.Cursor{Keys: filter.After.Keys, Before: !before, Sort: sort}.Encode()
+		if before {
+			page.NextCursor = back
+		} else {
+			page.PrevCursor = back
+		}
+		return page, nil
+	}
+	if !before || more {
+		page.PrevCursor = model.NewCursor(items[0], order, true, sort)
+	}
+	if before || more {
+		page.NextCursor = model.NewCursor(items[len(items)-1], order, false, sort)
+	}
+	return page, nil
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+)
+
+type SearchServiceI interface {
+	Search(ctx context.Context, filter model.SearchFilter) ([]model.SearchResult, error)
+}
+
+type SearchService struct {
+	userRepo repository.UserRepoI
+	taskRepo repository.TaskRepoI
+}
+
+func NewSearchService(userRepo repository.UserRepoI, taskRepo repository.TaskRepoI) *SearchService {
+	return &SearchService{userRepo: userRepo, taskRepo: taskRepo}
+}
+
+// Search finds users and tasks matching the query and returns the most relevant of them together.
+func (s *SearchService) Search(ctx context.Context, filter model.SearchFilter) ([]model.SearchResult, error) {
+	users, err := s.userRepo.SearchUsers(ctx, filter.Q, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.taskRepo.SearchTasks(ctx, filter.Q, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return repository.TopResults(append(users, tasks...), filter.Limit), nil
+}
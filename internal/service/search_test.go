@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"testing"
+)
+
+func TestSearchService_MergesByRank(t *testing.T) {
+	repos := newTestRepos()
+	petr := repos.createUser(t, model.User{Name: "Петр", Surname: "Отчетов"})
+	ivan := repos.createUser(t, model.User{Name: "Иван", Surname: "Годовой", Address: "ул. Отчетная, д. 1"})
+	partial := repos.createTask(t, model.Task{UserID: petr, Name: "отчет"})
+	full := repos.createTask(t, model.Task{UserID: petr, Name: "годовой отчет"})
+	repos.createTask(t, model.Task{UserID: petr, Name: "созвон"})
+	s := NewSearchService(repos.users, repos.tasks)
+
+	results, err := s.Search(context.Background(), model.SearchFilter{Q: "годовой отчет", Limit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type hit struct {
+		typ string
+		id  int
+	}
+	// Equal ranks put tasks first, so the user matching one word of the query is cut off.
+	want := []hit{{model.SearchTypeTask, full}, {model.SearchTypeUser, ivan}, {model.SearchTypeTask, partial}}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), results)
+	}
+	for i, r := range results {
+		if (hit{r.Type, r.ID()}) != want[i] {
+			t.Errorf("expected %v at %d, got %s %d with rank %v", want[i], i, r.Type, r.ID(), r.Rank)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"github.com/usmonzodasomon/time-tracker/internal/repository/memory"
+	"testing"
+)
+
+// testRepos are the memory repositories of one store shared by the services under test.
+type testRepos struct {
+	store *memory.Store
+	users *memory.UserRepo
+	tasks *memory.TaskRepo
+	tx    *countingTxManager
+
+	passports int
+}
+
+func newTestRepos() *testRepos {
+	store := memory.NewStore()
+	return &testRepos{
+		store: store,
+		users: memory.NewUserRepo(store),
+		tasks: memory.NewTaskRepo(store),
+		tx:    &countingTxManager{TxManagerI: memory.NewTxManager(store)},
+	}
+}
+
+// createUser creates the user with a passport of its own unless one is set.
+func (r *testRepos) createUser(t *testing.T, user model.User) int {
+	t.Helper()
+	if user.PassportSerie == 0 {
+		r.passports++
+		user.PassportSerie, user.PassportNumber = 1000, 1000+r.passports
+	}
+	id, err := r.users.CreateUser(context.Background(), user, model.AuditMeta{})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return id
+}
+
+func (r *testRepos) createTask(t *testing.T, task model.Task) int {
+	t.Helper()
+	id, err := r.tasks.CreateTask(context.Background(), task, model.AuditMeta{})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	return id
+}
+
+func (r *testRepos) startTask(t *testing.T, taskID int) {
+	t.Helper()
+	if err := r.tasks.StartTask(context.Background(), taskID, model.AuditMeta{}); err != nil {
+		t.Fatalf("StartTask: %v", err)
+	}
+}
+
+func (r *testRepos) isRunning(t *testing.T, taskID int) bool {
+	t.Helper()
+	started, err := r.tasks.IsTaskStarted(context.Background(), taskID)
+	if err != nil {
+		t.Fatalf("IsTaskStarted: %v", err)
+	}
+	return started
+}
+
+// countUsers returns the number of active users.
+func (r *testRepos) countUsers(t *testing.T) int {
+	t.Helper()
+	_, total, err := r.users.GetAllUsers(context.Background(), model.UserFilter{PageFilter: model.PageFilter{Page: 1, PerPage: 1}})
+	if err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	return total
+}
+
+// countingTxManager counts the transactions which were committed and rolled back.
+type countingTxManager struct {
+	repository.TxManagerI
+
+	commits, rollbacks int
+}
+
+func (m *countingTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := m.TxManagerI.WithinTx(ctx, fn)
+	if err != nil {
+		m.rollbacks++
+	} else {
+		m.commits++
+	}
+	return err
+}
+
+// failingStopUserTasks fails to stop the tasks of users, e.g. when the connection breaks.
+type failingStopUserTasks struct {
+	repository.TaskRepoI
+
+	err error
+}
+
+func (r failingStopUserTasks) StopUserTasks(context.Context, int, model.AuditMeta) (int, error) {
+	return 0, r.err
+}
@@ -1,67 +1,99 @@
 package service
 
 import (
+	"context"
+	"github.com/usmonzodasomon/time-tracker/internal/metrics"
 	"github.com/usmonzodasomon/time-tracker/internal/model"
 	"github.com/usmonzodasomon/time-tracker/internal/repository"
 )
 
 type TaskServiceI interface {
-	CreateTask(task model.Task) (int, error)
-	GetTask(id int) (model.Task, error)
-	StartTask(taskID int) error
-	IsTaskStarted(taskID int) (bool, error)
-	IsTaskStopped(taskID int) (bool, error)
-	StopTask(id int) error
+	CreateTask(ctx context.Context, task model.Task, meta model.AuditMeta) (int, error)
+	GetTask(ctx context.Context, id int) (model.Task, error)
+	StartTask(ctx context.Context, taskID int, meta model.AuditMeta) error
+	IsTaskStarted(ctx context.Context, taskID int) (bool, error)
+	IsTaskStopped(ctx context.Context, taskID int) (bool, error)
+	StopTask(ctx context.Context, id int, meta model.AuditMeta) error
 }
 
 type TaskService struct {
 	repo repository.TaskRepoI
+	tx   repository.TxManagerI
 }
 
-func NewTaskService(repo repository.TaskRepoI) *TaskService {
-	return &TaskService{repo: repo}
+func NewTaskService(repo repository.TaskRepoI, tx repository.TxManagerI) *TaskService {
+	return &TaskService{repo: repo, tx: tx}
 }
 
-func (s *TaskService) CreateTask(task model.Task) (int, error) {
-	return s.repo.CreateTask(task)
+func (s *TaskService) CreateTask(ctx context.Context, task model.Task, meta model.AuditMeta) (int, error) {
+	return s.repo.CreateTask(ctx, task, meta)
 }
 
-func (s *TaskService) GetTask(id int) (model.Task, error) {
-	task, err := s.repo.GetTask(id)
+func (s *TaskService) GetTask(ctx context.Context, id int) (model.Task, error) {
+	task, err := s.repo.GetTask(ctx, id)
 	if err != nil {
 		return model.Task{}, err
 	}
 	return task, nil
 }
 
-func (s *TaskService) StartTask(taskID int) error {
-	started, err := s.IsTaskStarted(taskID)
+// StartTask checks the task and starts a time entry for it in one transaction.
+// When another request starts the task concurrently, the repository rejects the second entry
+// with model.ErrTaskAlreadyStarted.
+func (s *TaskService) StartTask(ctx context.Context, taskID int, meta model.AuditMeta) error {
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if _, err := s.repo.GetTask(ctx, taskID); err != nil {
+			return err
+		}
+		started, err := s.IsTaskStarted(ctx, taskID)
+		if err != nil {
+			return err
+		}
+		if started {
+			return model.ErrTaskAlreadyStarted
+		}
+		return s.repo.StartTask(ctx, taskID, meta)
+	})
 	if err != nil {
 		return err
 	}
-	if started {
-		return model.ErrTaskAlreadyStarted
-	}
-	return s.repo.StartTask(taskID)
+	metrics.TasksStarted.Inc()
+	return nil
 }
 
-func (s *TaskService) IsTaskStarted(taskID int) (bool, error) {
-	return s.repo.IsTaskStarted(taskID)
+func (s *TaskService) IsTaskStarted(ctx context.Context, taskID int) (bool, error) {
+	return s.repo.IsTaskStarted(ctx, taskID)
 }
 
-func (s *TaskService) IsTaskStopped(taskID int) (bool, error) {
-	return s.repo.IsTaskStopped(taskID)
+func (s *TaskService) IsTaskStopped(ctx context.Context, taskID int) (bool, error) {
+	return s.repo.IsTaskStopped(ctx, taskID)
 
 }
 
-func (s *TaskService) StopTask(id int) error {
-	stopped, err := s.IsTaskStopped(id)
+// StopTask stops the running time entry of the task. A task without one, either never started
+// or stopped already, e.g. by a concurrent request, fails with model.ErrTaskAlreadyStopped.
+func (s *TaskService) StopTask(ctx context.Context, id int, meta model.AuditMeta) error {
+	var stopped int
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		started, err := s.IsTaskStarted(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !started {
+			return model.ErrTaskAlreadyStopped
+		}
+		stopped, err = s.repo.StopTask(ctx, id, meta)
+		if err != nil {
+			return err
+		}
+		if stopped == 0 {
+			return model.ErrTaskAlreadyStopped
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	if stopped {
-		return model.ErrTaskAlreadyStopped
-	}
-
-	return s.repo.StopTask(id)
+	metrics.TasksStopped.Add(float64(stopped))
+	return nil
 }
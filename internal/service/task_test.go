@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/usmonzodasomon/time-tracker/internal/metrics"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"testing"
+)
+
+func TestTaskService_StartTask(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	task := repos.createTask(t, model.Task{UserID: repos.createUser(t, model.User{})})
+	s := NewTaskService(repos.tasks, repos.tx)
+
+	if err := s.StartTask(ctx, task, model.AuditMeta{}); err != nil {
+		t.Fatalf("StartTask: %v", err)
+	}
+	if !repos.isRunning(t, task) {
+		t.Fatal("task was not started")
+	}
+	if err := s.StartTask(ctx, task, model.AuditMeta{}); !errors.Is(err, model.ErrTaskAlreadyStarted) {
+		t.Fatalf("expected ErrTaskAlreadyStarted, got %v", err)
+	}
+}
+
+func TestTaskService_StartTaskOfDeletedUser(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	user := repos.createUser(t, model.User{})
+	task := repos.createTask(t, model.Task{UserID: user})
+	if err := NewUserService(repos.users, repos.tasks, repos.tx).DeleteUser(ctx, user, model.AuditMeta{}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	s := NewTaskService(repos.tasks, repos.tx)
+	if err := s.StartTask(ctx, task, model.AuditMeta{}); !errors.Is(err, model.ErrTaskNotFound) {
+		t.Fatalf("expected ErrTaskNotFound, got %v", err)
+	}
+	if repos.isRunning(t, task) {
+		t.Fatal("task of deleted user must not be started")
+	}
+	if _, err := s.GetTask(ctx, task); !errors.Is(err, model.ErrTaskNotFound) {
+		t.Fatalf("expected task of deleted user to be hidden, got %v", err)
+	}
+}
+
+func TestTaskService_StopTaskCountsStoppedEntries(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	task := repos.createTask(t, model.Task{UserID: repos.createUser(t, model.User{})})
+	s := NewTaskService(repos.tasks, repos.tx)
+	stoppedBefore := testutil.ToFloat64(metrics.TasksStopped)
+
+	// A task which was never started has nothing to stop.
+	if err := s.StopTask(ctx, task, model.AuditMeta{}); !errors.Is(err, model.ErrTaskAlreadyStopped) {
+		t.Fatalf("expected ErrTaskAlreadyStopped, got %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.TasksStopped) - stoppedBefore; got != 0 {
+		t.Fatalf("expected no stopped timers to be counted, got %v", got)
+	}
+
+	if err := s.StartTask(ctx, task, model.AuditMeta{}); err != nil {
+		t.Fatalf("StartTask: %v", err)
+	}
+	if err := s.StopTask(ctx, task, model.AuditMeta{}); err != nil {
+		t.Fatalf("StopTask: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.TasksStopped) - stoppedBefore; got != 1 {
+		t.Errorf("expected one stopped timer to be counted, got %v", got)
+	}
+}
+
+func TestTaskService_StopRestartedTask(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	task := repos.createTask(t, model.Task{UserID: repos.createUser(t, model.User{}), Name: "report"})
+	s := NewTaskService(repos.tasks, repos.tx)
+
+	for i := range 2 {
+		if err := s.StartTask(ctx, task, model.AuditMeta{}); err != nil {
+			t.Fatalf("start %d: %v", i+1, err)
+		}
+		if err := s.StopTask(ctx, task, model.AuditMeta{}); err != nil {
+			t.Fatalf("stop %d: %v", i+1, err)
+		}
+	}
+	if err := s.StopTask(ctx, task, model.AuditMeta{}); !errors.Is(err, model.ErrTaskAlreadyStopped) {
+		t.Fatalf("expected ErrTaskAlreadyStopped, got %v", err)
+	}
+	if running, err := repos.tasks.CountRunningTasks(ctx); err != nil || running != 0 {
+		t.Errorf("expected no running entries, got %d, %v", running, err)
+	}
+}
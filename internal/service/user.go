@@ -1,38 +1,76 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/usmonzodasomon/time-tracker/internal/metrics"
 	"github.com/usmonzodasomon/time-tracker/internal/model"
 	"github.com/usmonzodasomon/time-tracker/internal/repository"
+	"slices"
 	"time"
 )
 
 type UserServiceI interface {
-	GetAllUsers(filter model.UserFilter) ([]model.User, error)
-	GetUserTimeSpent(userID int, startPeriod, endPeriod time.Time) ([]model.UserTaskTimeSpent, error)
+	GetAllUsers(ctx context.Context, filter model.UserFilter) (model.Page[model.User], error)
+	GetUserTimeSpent(ctx context.Context, userID int, startPeriod, endPeriod time.Time) ([]model.UserTaskTimeSpent, error)
+	GetUserTasks(ctx context.Context, userID int, filter model.PageFilter) (model.Page[model.Task], error)
+	GetUserTimeEntries(ctx context.Context, userID int, filter model.PageFilter) (model.Page[model.TimeEntry], error)
 
-	GetUser(id int) (model.User, error)
-	CreateUser(user model.User) (int, error)
-	DeleteUser(id int) error
-	UpdateUser(user model.User) error
+	GetUser(ctx context.Context, id int) (model.User, error)
+	GetUserDetails(ctx context.Context, id int, include model.UserInclude) (model.UserDetails, error)
+	CreateUser(ctx context.Context, user model.User, meta model.AuditMeta) (int, error)
+	DeleteUser(ctx context.Context, id int, meta model.AuditMeta) error
+	UpdateUser(ctx context.Context, user model.User, meta model.AuditMeta) error
+	RestoreUser(ctx context.Context, id int, meta model.AuditMeta) error
+	HardDeleteUser(ctx context.Context, id int, meta model.AuditMeta) error
+	ExportUser(ctx context.Context, id int) (model.UserExport, error)
+	EraseUser(ctx context.Context, id int, meta model.AuditMeta) error
+	GetUserHistory(ctx context.Context, id int) ([]model.UserHistoryEntry, error)
+	GetUserAsOf(ctx context.Context, id int, at time.Time) (model.User, error)
 }
 
 type UserService struct {
-	repo repository.UserRepoI
+	repo     repository.UserRepoI
+	taskRepo repository.TaskRepoI
+	tx       repository.TxManagerI
 }
 
-func NewUserService(repo repository.UserRepoI) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo repository.UserRepoI, taskRepo repository.TaskRepoI, tx repository.TxManagerI) *UserService {
+	return &UserService{repo: repo, taskRepo: taskRepo, tx: tx}
 }
-func (s *UserService) GetAllUsers(filter model.UserFilter) ([]model.User, error) {
-	return s.repo.GetAllUsers(filter)
+func (s *UserService) GetAllUsers(ctx context.Context, filter model.UserFilter) (model.Page[model.User], error) {
+	return listPage(filter.PageFilter, model.KeysetOrder(filter.SortBy), filter.Sort,
+		func(page model.PageFilter) ([]model.User, int, error) {
+			filter.PageFilter = page
+			return s.repo.GetAllUsers(ctx, filter)
+		})
 }
-func (s *UserService) GetUserTimeSpent(userID int, startPeriod, endPeriod time.Time) ([]model.UserTaskTimeSpent, error) {
-	_, err := s.GetUser(userID)
+
+func (s *UserService) GetUserTasks(ctx context.Context, userID int, filter model.PageFilter) (model.Page[model.Task], error) {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return model.Page[model.Task]{}, err
+	}
+	return listPage(filter, model.TaskOrder, "", func(page model.PageFilter) ([]model.Task, int, error) {
+		return s.taskRepo.ListUserTasks(ctx, userID, page)
+	})
+}
+
+func (s *UserService) GetUserTimeEntries(ctx context.Context, userID int, filter model.PageFilter) (model.Page[model.TimeEntry], error) {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return model.Page[model.TimeEntry]{}, err
+	}
+	return listPage(filter, model.TimeEntryOrder, "", func(page model.PageFilter) ([]model.TimeEntry, int, error) {
+		return s.taskRepo.ListUserTimeEntries(ctx, userID, page)
+	})
+}
+
+func (s *UserService) GetUserTimeSpent(ctx context.Context, userID int, startPeriod, endPeriod time.Time) ([]model.UserTaskTimeSpent, error) {
+	_, err := s.GetUser(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	timeSpentMinutes, err := s.repo.GetUserTimeSpent(userID, startPeriod, endPeriod)
+	timeSpentMinutes, err := s.repo.GetUserTimeSpent(ctx, userID, startPeriod, endPeriod)
 	if err != nil {
 		return nil, fmt.Errorf("error getting user time spent: %w", err)
 	}
@@ -51,22 +89,245 @@ func (s *UserService) GetUserTimeSpent(userID int, startPeriod, endPeriod time.T
 	return userTaskTimeSpent, nil
 }
 
-func (s *UserService) GetUser(id int) (model.User, error) {
-	return s.repo.GetUser(id)
+func (s *UserService) GetUser(ctx context.Context, id int) (model.User, error) {
+	return s.repo.GetUser(ctx, id)
+}
+
+// GetUserDetails returns the user together with the expansions selected by include.
+func (s *UserService) GetUserDetails(ctx context.Context, id int, include model.UserInclude) (model.UserDetails, error) {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return model.UserDetails{}, err
+	}
+	details := model.UserDetails{User: user}
+	// Weeks start on Monday in UTC, the zone the storages keep their timestamps in.
+	now := time.Now().UTC()
+
+	var tasks []model.Task
+	if include.Tasks || include.Running {
+		if tasks, err = s.taskRepo.GetUserTasks(ctx, id); err != nil {
+			return model.UserDetails{}, fmt.Errorf("error getting user tasks: %w", err)
+		}
+	}
+	if include.Tasks {
+		if tasks == nil {
+			tasks = []model.Task{}
+		}
+		details.Tasks = &tasks
+	}
+
+	if include.Running {
+		entries, err := s.taskRepo.GetUserRunningEntries(ctx, id)
+		if err != nil {
+			return model.UserDetails{}, fmt.Errorf("error getting running time entries: %w", err)
+		}
+		running := make([]model.RunningTimer, 0, len(entries))
+		for _, e := range entries {
+			timer := model.RunningTimer{
+				TaskID:         e.TaskID,
+				EntryID:        e.ID,
+				StartTime:      e.StartTime,
+				ElapsedMinutes: int(now.Sub(e.StartTime).Minutes()),
+			}
+			if i := slices.IndexFunc(tasks, func(t model.Task) bool { return t.ID == e.TaskID }); i >= 0 {
+				timer.TaskName = tasks[i].Name
+			}
+			running = append(running, timer)
+		}
+		details.Running = &running
+	}
+
+	if include.Totals {
+		weekStart := model.WeekStart(now)
+		lifetime, err := s.repo.GetUserTimeSpent(ctx, id, time.Time{}, now)
+		if err != nil {
+			return model.UserDetails{}, fmt.Errorf("error getting lifetime time spent: %w", err)
+		}
+		week, err := s.repo.GetUserTimeSpent(ctx, id, weekStart, now)
+		if err != nil {
+			return model.UserDetails{}, fmt.Errorf("error getting week time spent: %w", err)
+		}
+		details.Totals = &model.UserTotals{
+			LifetimeMinutes: totalMinutes(lifetime),
+			WeekMinutes:     totalMinutes(week),
+			WeekStart:       weekStart,
+		}
+	}
+	return details, nil
+}
+
+func totalMinutes(spent []model.TaskTimeSpent) int {
+	var total float64
+	for _, v := range spent {
+		total += v.TotalMinutes
+	}
+	return int(total)
 }
 
-func (s *UserService) CreateUser(user model.User) (int, error) {
-	return s.repo.CreateUser(user)
+// CreateUser creates the user. When an active user already has the passport,
+// the returned error carries the ID of that user.
+func (s *UserService) CreateUser(ctx context.Context, user model.User, meta model.AuditMeta) (int, error) {
+	id, err := s.repo.CreateUser(ctx, user, meta)
+	if !errors.Is(err, model.ErrUserAlreadyExists) {
+		return id, err
+	}
+	existing, lookupErr := s.repo.GetUserByPassport(ctx, user.PassportSerie, user.PassportNumber)
+	if lookupErr != nil {
+		// The conflicting user may have been deleted in the meantime.
+		return 0, err
+	}
+	return 0, model.NewUserConflictError(existing.ID, err)
 }
 
-func (s *UserService) UpdateUser(user model.User) error {
-	return s.repo.UpdateUser(user)
+func (s *UserService) UpdateUser(ctx context.Context, user model.User, meta model.AuditMeta) error {
+	return s.repo.UpdateUser(ctx, user, meta)
 }
 
-func (s *UserService) DeleteUser(id int) error {
-	_, err := s.GetUser(id)
+// DeleteUser soft-deletes the user and stops all of their running time entries in one transaction.
+// Tasks of a deleted user are hidden from lookups and cannot be started.
+func (s *UserService) DeleteUser(ctx context.Context, id int, meta model.AuditMeta) error {
+	var stopped int
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := s.GetUser(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.DeleteUser(ctx, id, meta); err != nil {
+			return err
+		}
+		if stopped, err = s.taskRepo.StopUserTasks(ctx, id, meta); err != nil {
+			return fmt.Errorf("error stopping user tasks: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
-	return s.repo.DeleteUser(id)
+	metrics.TasksStopped.Add(float64(stopped))
+	return nil
+}
+
+func (s *UserService) RestoreUser(ctx context.Context, id int, meta model.AuditMeta) error {
+	return s.repo.RestoreUser(ctx, id, meta)
+}
+
+func (s *UserService) HardDeleteUser(ctx context.Context, id int, meta model.AuditMeta) error {
+	return s.repo.HardDeleteUser(ctx, id, meta)
+}
+
+// ExportUser collects the profile, tasks and time entries of the user, including deleted ones.
+func (s *UserService) ExportUser(ctx context.Context, id int) (model.UserExport, error) {
+	user, err := s.repo.GetUserWithDeleted(ctx, id)
+	if err != nil {
+		return model.UserExport{}, err
+	}
+	tasks, err := s.taskRepo.GetUserTasks(ctx, id)
+	if err != nil {
+		return model.UserExport{}, fmt.Errorf("error getting user tasks: %w", err)
+	}
+	entries, err := s.taskRepo.GetUserTimeEntries(ctx, id)
+	if err != nil {
+		return model.UserExport{}, fmt.Errorf("error getting user time entries: %w", err)
+	}
+
+	taskEntries := make(map[int][]model.TimeEntry, len(tasks))
+	for _, e := range entries {
+		taskEntries[e.TaskID] = append(taskEntries[e.TaskID], e)
+	}
+
+	export := model.UserExport{
+		ExportedAt: time.Now().UTC(),
+		User:       model.NewExportedUser(user),
+		Tasks:      make([]model.TaskExport, 0, len(tasks)),
+	}
+	for _, t := range tasks {
+		taskExport := model.TaskExport{Task: t, Entries: taskEntries[t.ID]}
+		if taskExport.Entries == nil {
+			taskExport.Entries = []model.TimeEntry{}
+		}
+		export.Tasks = append(export.Tasks, taskExport)
+	}
+	return export, nil
+}
+
+// EraseUser stops running time entries of the user and anonymizes their personal data in one transaction.
+func (s *UserService) EraseUser(ctx context.Context, id int, meta model.AuditMeta) error {
+	var stopped int
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		_, err := s.repo.GetUserWithDeleted(ctx, id)
+		if err != nil {
+			return err
+		}
+		if stopped, err = s.taskRepo.StopUserTasks(ctx, id, meta); err != nil {
+			return fmt.Errorf("error stopping user tasks: %w", err)
+		}
+		return s.repo.EraseUser(ctx, id, meta)
+	})
+	if err != nil {
+		return err
+	}
+	metrics.TasksStopped.Add(float64(stopped))
+	return nil
+}
+
+// GetUserHistory returns profile changes of the user, each compared to the previous version.
+func (s *UserService) GetUserHistory(ctx context.Context, id int) ([]model.UserHistoryEntry, error) {
+	if _, err := s.GetUser(ctx, id); err != nil {
+		return nil, err
+	}
+	versions, err := s.repo.GetUserHistory(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user history: %w", err)
+	}
+
+	history := make([]model.UserHistoryEntry, 0, len(versions))
+	var prev model.UserVersion
+	for _, v := range versions {
+		history = append(history, model.UserHistoryEntry{
+			Version:   v.Version,
+			ChangedBy: v.ChangedBy,
+			ChangedAt: v.ChangedAt,
+			Changes:   diffUserVersions(prev, v),
+		})
+		prev = v
+	}
+	return history, nil
+}
+
+// GetUserAsOf returns the user with the profile fields they had at the given moment.
+func (s *UserService) GetUserAsOf(ctx context.Context, id int, at time.Time) (model.User, error) {
+	user, err := s.GetUser(ctx, id)
+	if err != nil {
+		return model.User{}, err
+	}
+	version, err := s.repo.GetUserVersionAt(ctx, id, at)
+	if err != nil {
+		return model.User{}, err
+	}
+
+	user.Name = version.Name
+	user.Surname = version.Surname
+	user.Patronymic = version.Patronymic
+	user.Address = version.Address
+	return user, nil
+}
+
+func diffUserVersions(prev, next model.UserVersion) []model.UserFieldChange {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"name", prev.Name, next.Name},
+		{"surname", prev.Surname, next.Surname},
+		{"patronymic", prev.Patronymic, next.Patronymic},
+		{"address", prev.Address, next.Address},
+	}
+
+	changes := make([]model.UserFieldChange, 0, len(fields))
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, model.UserFieldChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+	return changes
 }
@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/usmonzodasomon/time-tracker/internal/metrics"
+	"github.com/usmonzodasomon/time-tracker/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestUserService_DeleteUserStopsRunningTasks(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	user, other := repos.createUser(t, model.User{}), repos.createUser(t, model.User{})
+	running := repos.createTask(t, model.Task{UserID: user})
+	idle := repos.createTask(t, model.Task{UserID: user})
+	otherRunning := repos.createTask(t, model.Task{UserID: other})
+	repos.startTask(t, running)
+	repos.startTask(t, otherRunning)
+	stoppedBefore := testutil.ToFloat64(metrics.TasksStopped)
+
+	s := NewUserService(repos.users, repos.tasks, repos.tx)
+	if err := s.DeleteUser(ctx, user, model.AuditMeta{}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if got := testutil.ToFloat64(metrics.TasksStopped) - stoppedBefore; got != 1 {
+		t.Errorf("expected 1 stopped task to be counted, got %v", got)
+	}
+
+	if repos.isRunning(t, running) {
+		t.Error("running task of deleted user was not stopped")
+	}
+	entries, err := repos.tasks.GetUserTimeEntries(ctx, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].TaskID != running || entries[0].EndTime == nil {
+		t.Errorf("expected only the stopped entry of task %d and none of idle task %d, got %+v", running, idle, entries)
+	}
+	if !repos.isRunning(t, otherRunning) {
+		t.Error("task of another user must keep running")
+	}
+}
+
+func TestUserService_CreateUserConflict(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	existing := repos.createUser(t, model.User{PassportSerie: 1234, PassportNumber: 5678})
+	s := NewUserService(repos.users, repos.tasks, repos.tx)
+
+	_, err := s.CreateUser(ctx, model.User{PassportSerie: 1234, PassportNumber: 5678}, model.AuditMeta{})
+	var domainErr *model.Error
+	if !errors.Is(err, model.ErrUserAlreadyExists) || !errors.As(err, &domainErr) || domainErr.ExistingID != existing {
+		t.Fatalf("expected conflict with user %d, got %v", existing, err)
+	}
+}
+
+func TestUserService_DeleteUserNotFound(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	s := NewUserService(repos.users, repos.tasks, repos.tx)
+
+	if err := s.DeleteUser(ctx, 1, model.AuditMeta{}); !errors.Is(err, model.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUserService_DeleteUserRollsBackWhenStoppingTasksFails(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	user := repos.createUser(t, model.User{})
+	task := repos.createTask(t, model.Task{UserID: user})
+	repos.startTask(t, task)
+	s := NewUserService(repos.users, failingStopUserTasks{TaskRepoI: repos.tasks, err: errors.New("connection reset")}, repos.tx)
+
+	if err := s.DeleteUser(ctx, user, model.AuditMeta{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if repos.tx.rollbacks != 1 || repos.tx.commits != 0 {
+		t.Fatalf("expected rollback, got %d commits and %d rollbacks", repos.tx.commits, repos.tx.rollbacks)
+	}
+	if _, err := s.GetUser(ctx, user); err != nil {
+		t.Errorf("user must not be deleted after rollback: %v", err)
+	}
+	if !repos.isRunning(t, task) {
+		t.Error("task must keep running after rollback")
+	}
+}
+
+func TestUserService_ExportUser(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	user := repos.createUser(t, model.User{PassportSerie: 1234, PassportNumber: 5678, Name: "Петр"})
+	other := repos.createUser(t, model.User{})
+	tracked := repos.createTask(t, model.Task{UserID: user})
+	idle := repos.createTask(t, model.Task{UserID: user})
+	otherTask := repos.createTask(t, model.Task{UserID: other})
+	start := time.Now().Add(-time.Hour)
+	end := start.Add(30 * time.Minute)
+	entry := repos.store.AddTimeEntry(tracked, start, end)
+	repos.store.AddTimeEntry(otherTask, start, end)
+	s := NewUserService(repos.users, repos.tasks, repos.tx)
+	if err := s.DeleteUser(ctx, user, model.AuditMeta{}); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	export, err := s.ExportUser(ctx, user)
+	if err != nil {
+		t.Fatalf("ExportUser: %v", err)
+	}
+	want := model.ExportedUser{ID: user, PassportSerie: 1234, PassportNumber: 5678, Name: "Петр", IsDeleted: true}
+	if export.User != want {
+		t.Errorf("expected deleted user to be exported, got %+v", export.User)
+	}
+	slices.SortFunc(export.Tasks, func(a, b model.TaskExport) int { return a.Task.ID - b.Task.ID })
+	if len(export.Tasks) != 2 || export.Tasks[0].Task.ID != tracked || export.Tasks[1].Task.ID != idle {
+		t.Fatalf("expected tasks %d and %d, got %+v", tracked, idle, export.Tasks)
+	}
+	if len(export.Tasks[0].Entries) != 1 || export.Tasks[0].Entries[0].ID != entry {
+		t.Errorf("expected entry %d in task %d, got %+v", entry, tracked, export.Tasks[0].Entries)
+	}
+	if export.Tasks[1].Entries == nil || len(export.Tasks[1].Entries) != 0 {
+		t.Errorf("expected empty entries of task %d, got %#v", idle, export.Tasks[1].Entries)
+	}
+
+	if _, err := s.ExportUser(ctx, other+1); !errors.Is(err, model.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUserService_EraseUser(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	user := repos.createUser(t, model.User{PassportSerie: 1234, PassportNumber: 5678, Name: "Петр"})
+	task := repos.createTask(t, model.Task{UserID: user})
+	repos.startTask(t, task)
+	s := NewUserService(repos.users, repos.tasks, repos.tx)
+
+	if err := s.EraseUser(ctx, user, model.AuditMeta{Actor: "dpo"}); err != nil {
+		t.Fatalf("EraseUser: %v", err)
+	}
+	if repos.isRunning(t, task) {
+		t.Error("running task of erased user was not stopped")
+	}
+	erased, err := repos.users.GetUserWithDeleted(ctx, user)
+	if err != nil {
+		t.Fatalf("GetUserWithDeleted: %v", err)
+	}
+	if erased != (model.User{ID: user, IsDeleted: true}) {
+		t.Errorf("expected anonymized user, got %+v", erased)
+	}
+
+	if err := s.EraseUser(ctx, user+1, model.AuditMeta{}); !errors.Is(err, model.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestUserService_EraseUserRollsBackWhenStoppingTasksFails(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	user := repos.createUser(t, model.User{Name: "Петр"})
+	repos.startTask(t, repos.createTask(t, model.Task{UserID: user}))
+	s := NewUserService(repos.users, failingStopUserTasks{TaskRepoI: repos.tasks, err: errors.New("connection reset")}, repos.tx)
+
+	if err := s.EraseUser(ctx, user, model.AuditMeta{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if u, err := s.GetUser(ctx, user); err != nil || u.Name != "Петр" {
+		t.Errorf("user must not be erased after rollback, got %+v, %v", u, err)
+	}
+}
+
+func TestUserService_GetUserHistory(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	s := NewUserService(repos.users, repos.tasks, repos.tx)
+	beforeCreate := time.Now().Add(-time.Millisecond)
+	user := model.User{PassportSerie: 1234, PassportNumber: 5678, Name: "Петр", Surname: "Петров", Address: "ул. Петрова, д. 1"}
+	id, err := s.CreateUser(ctx, user, model.AuditMeta{Actor: "system"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both versions must be told apart by the microseconds the storage keeps.
+	time.Sleep(time.Millisecond)
+	beforeUpdate := time.Now()
+	time.Sleep(time.Millisecond)
+	user.ID, user.Address = id, "ул. Новая, д. 5"
+	if err := s.UpdateUser(ctx, user, model.AuditMeta{Actor: "admin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := s.GetUserHistory(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUserHistory: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 history entries, got %d", len(history))
+	}
+	if len(history[0].Changes) != 3 {
+		t.Errorf("expected initial version to set 3 fields, got %v", history[0].Changes)
+	}
+	want := model.UserFieldChange{Field: "address", Old: "ул. Петрова, д. 1", New: "ул. Новая, д. 5"}
+	if len(history[1].Changes) != 1 || history[1].Changes[0] != want {
+		t.Errorf("expected %v, got %v", want, history[1].Changes)
+	}
+	if history[1].ChangedBy != "admin" {
+		t.Errorf("expected change by admin, got %s", history[1].ChangedBy)
+	}
+
+	old, err := s.GetUserAsOf(ctx, id, beforeUpdate)
+	if err != nil {
+		t.Fatalf("GetUserAsOf: %v", err)
+	}
+	if old.Address != "ул. Петрова, д. 1" {
+		t.Errorf("expected old address, got %s", old.Address)
+	}
+	if _, err := s.GetUserAsOf(ctx, id, beforeCreate); !errors.Is(err, model.ErrUserVersionNotFound) {
+		t.Errorf("expected ErrUserVersionNotFound, got %v", err)
+	}
+}
+
+func TestUserService_GetUserTasksCursors(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	user := repos.createUser(t, model.User{})
+	for range 5 {
+		repos.createTask(t, model.Task{UserID: user})
+	}
+	s := NewUserService(repos.users, repos.tasks, repos.tx)
+
+	ids := func(page model.Page[model.Task]) []int {
+		var ids []int
+		for _, t := range page.Items {
+			ids = append(ids, t.ID)
+		}
+		return ids
+	}
+	follow := func(cursor string) model.Page[model.Task] {
+		t.Helper()
+		if cursor == "" {
+			t.Fatal("expected a cursor")
+		}
+		after, err := model.DecodeCursor(cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		page, err := s.GetUserTasks(ctx, user, model.PageFilter{PerPage: 2, After: after})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return page
+	}
+
+	first, err := s.GetUserTasks(ctx, user, model.PageFilter{Page: 1, PerPage: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids(first), []int{1, 2}) || first.Total != 5 || first.PrevCursor != "" {
+		t.Fatalf("unexpected first page %+v", first)
+	}
+	second := follow(first.NextCursor)
+	if !slices.Equal(ids(second), []int{3, 4}) || second.PrevCursor == "" {
+		t.Fatalf("unexpected second page %+v", second)
+	}
+	last := follow(second.NextCursor)
+	if !slices.Equal(ids(last), []int{5}) || last.NextCursor != "" {
+		t.Fatalf("unexpected last page %+v", last)
+	}
+	back := follow(last.PrevCursor)
+	if !slices.Equal(ids(back), []int{3, 4}) || back.NextCursor == "" || back.PrevCursor == "" {
+		t.Fatalf("unexpected page before the last one %+v", back)
+	}
+	start := follow(back.PrevCursor)
+	if !slices.Equal(ids(start), []int{1, 2}) || start.PrevCursor != "" || start.NextCursor == "" {
+		t.Fatalf("unexpected page before the second one %+v", start)
+	}
+
+	if _, err := s.GetUserTasks(ctx, user+1, model.PageFilter{Page: 1, PerPage: 2}); !errors.Is(err, model.ErrUserNotFound) {
+		t.Fatalf("expected user not found, got %v", err)
+	}
+}
+
+func TestUserService_GetUserDetails(t *testing.T) {
+	ctx := context.Background()
+	repos := newTestRepos()
+	user := repos.createUser(t, model.User{Name: "Петр"})
+	report := repos.createTask(t, model.Task{UserID: user, Name: "report"})
+	review := repos.createTask(t, model.Task{UserID: user, Name: "review"})
+	s := NewUserService(repos.users, repos.tasks, repos.tx)
+
+	details, err := s.GetUserDetails(ctx, user, model.UserInclude{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.User.Name != "Петр" || details.Tasks != nil || details.Running != nil || details.Totals != nil {
+		t.Fatalf("expected only the user without expansions, got %+v", details)
+	}
+
+	weekStart := model.WeekStart(time.Now().UTC())
+	// The entry of this week must have ended already, even right after the week starts.
+	weekSpent := min(30*time.Minute, time.Since(weekStart)).Truncate(time.Minute)
+	lastWeekEnd, weekEnd := weekStart.Add(-47*time.Hour), weekStart.Add(weekSpent)
+	repos.store.AddTimeEntry(report, weekStart.Add(-48*time.Hour), lastWeekEnd)
+	repos.store.AddTimeEntry(review, weekStart, weekEnd)
+	// The timer started just now adds less than a minute to the totals.
+	repos.startTask(t, review)
+
+	details, err = s.GetUserDetails(ctx, user, model.UserInclude{Tasks: true, Running: true, Totals: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Tasks == nil || len(*details.Tasks) != 2 {
+		t.Errorf("expected 2 tasks, got %+v", details.Tasks)
+	}
+	if details.Running == nil || len(*details.Running) != 1 || (*details.Running)[0].TaskName != "review" {
+		t.Errorf("expected running timer of task review, got %+v", details.Running)
+	}
+	want := model.UserTotals{LifetimeMinutes: 60 + int(weekSpent.Minutes()), WeekMinutes: int(weekSpent.Minutes()), WeekStart: weekStart}
+	if details.Totals == nil || *details.Totals != want {
+		t.Errorf("expected totals %+v, got %+v", want, details.Totals)
+	}
+
+	if _, err := s.GetUserDetails(ctx, user+1, model.UserInclude{Tasks: true}); !errors.Is(err, model.ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_passport_serie_passport_number_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_passport_active ON users (passport_serie, passport_number) WHERE is_deleted = false;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_users_passport_active;
+ALTER TABLE users ADD CONSTRAINT users_passport_serie_passport_number_key UNIQUE (passport_serie, passport_number);
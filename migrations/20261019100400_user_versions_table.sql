@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_versions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    version INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    surname VARCHAR(255) NOT NULL,
+    patronymic VARCHAR(255),
+    address TEXT NOT NULL,
+    changed_by VARCHAR(255) NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT now(),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE (user_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_versions_user_id_changed_at ON user_versions (user_id, changed_at);
+
+-- Existing users are dated to the epoch: they had their current profile at any moment before history was kept.
+INSERT INTO user_versions (user_id, version, name, surname, patronymic, address, changed_by, changed_at)
+SELECT id, 1, name, surname, COALESCE(patronymic, ''), address, 'system', 'epoch' FROM users;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_versions;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Substring filters of the user listing (ILIKE '%x%').
+CREATE INDEX IF NOT EXISTS idx_users_name_trgm ON users USING gin (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_surname_trgm ON users USING gin (surname gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_patronymic_trgm ON users USING gin (patronymic gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_address_trgm ON users USING gin (address gin_trgm_ops);
+
+-- Search documents, the expressions must match the ones in the search queries.
+CREATE INDEX IF NOT EXISTS idx_users_search_fts ON users USING gin (
+    to_tsvector('russian', name || ' ' || surname || ' ' || coalesce(patronymic, '') || ' ' || address));
+CREATE INDEX IF NOT EXISTS idx_users_search_trgm ON users USING gin (
+    (name || ' ' || surname || ' ' || coalesce(patronymic, '') || ' ' || address) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_tasks_search_fts ON tasks USING gin (
+    to_tsvector('russian', name || ' ' || coalesce(description, '')));
+CREATE INDEX IF NOT EXISTS idx_tasks_search_trgm ON tasks USING gin (
+    (name || ' ' || coalesce(description, '')) gin_trgm_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_tasks_search_trgm;
+DROP INDEX IF EXISTS idx_tasks_search_fts;
+DROP INDEX IF EXISTS idx_users_search_trgm;
+DROP INDEX IF EXISTS idx_users_search_fts;
+DROP INDEX IF EXISTS idx_users_address_trgm;
+DROP INDEX IF EXISTS idx_users_patronymic_trgm;
+DROP INDEX IF EXISTS idx_users_surname_trgm;
+DROP INDEX IF EXISTS idx_users_name_trgm;
+-- pg_trgm is kept, it may have been installed before and be used by other objects.
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Entries started concurrently with the first running entry of a task are closed
+-- at their start, so that their time is not counted twice.
+UPDATE time_entries SET end_time = start_time
+WHERE end_time IS NULL
+AND id NOT IN (SELECT MIN(id) FROM time_entries WHERE end_time IS NULL GROUP BY task_id);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_time_entries_running_task_id ON time_entries (task_id) WHERE end_time IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_time_entries_running_task_id;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Owner is the token of the request holding the reservation, so that a request whose
+-- reservation was taken over can no longer complete or delete the record of the new one.
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS owner VARCHAR(32) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS owner;
+-- +goose StatementEnd
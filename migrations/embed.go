@@ -0,0 +1,14 @@
+// Package migrations embeds the SQL migrations so that the binary can apply them without the source tree.
+package migrations
+
+import "embed"
+
+// FS holds the Postgres migrations.
+//
+//go:embed *.sql
+var FS embed.FS
+
+// SQLiteFS holds the SQLite migrations in the sqlite directory.
+//
+//go:embed sqlite/*.sql
+var SQLiteFS embed.FS
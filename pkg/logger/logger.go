@@ -1,8 +1,11 @@
 package logger
 
 import (
+	"context"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 )
 
 const (
@@ -13,18 +16,81 @@ const (
 
 var Logger *slog.Logger
 
-func InitLogger(env string) {
-	Logger = GetLogger(env)
+type Config struct {
+	Env   string `yaml:"-"`
+	Level string `yaml:"level"`
 }
 
-func GetLogger(env string) *slog.Logger {
-	if env == envProd {
-		return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		}))
+func InitLogger(cfg Config) {
+	Logger = GetLogger(cfg)
+}
+
+// GetLogger returns a JSON logger for prod and a text logger otherwise.
+// The level defaults to info for prod and debug otherwise unless set explicitly.
+func GetLogger(cfg Config) *slog.Logger {
+	level := slog.LevelDebug
+	if cfg.Env == envProd {
+		level = slog.LevelInfo
+	}
+	if cfg.Level != "" {
+		if l, err := ParseLevel(cfg.Level); err == nil {
+			level = l
+		}
 	}
-	return slog.New(slog.NewTextHandler(os.Stdout,
+
+	if cfg.Env == envProd {
+		return slog.New(NewContextHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+			Level: level,
+		})))
+	}
+	return slog.New(NewContextHandler(slog.NewTextHandler(os.Stdout,
 		&slog.HandlerOptions{
-			Level: slog.LevelDebug,
-		}))
+			Level: level,
+		})))
+}
+
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", level)
+	}
+	return l, nil
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID,
+// which is added to every record logged with that context.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID stored in ctx or an empty string.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// contextHandler adds values stored in the context, such as the request ID, to log records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(h slog.Handler) slog.Handler {
+	return contextHandler{h}
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if requestID := RequestID(ctx); requestID != "" {
+		r.AddAttrs(slog.String("request_id", requestID))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
 }
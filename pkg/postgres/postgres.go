@@ -2,30 +2,57 @@ package postgres
 
 import (
 	"fmt"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
+	"time"
 )
 
 type Config struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"database"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	QueryTimeout    time.Duration `yaml:"query_timeout"`
 }
 
+// TimeZone is the session time zone of the connections. TIMESTAMP columns hold wall times of it,
+// so Go times are converted to it before they are compared with or stored into those columns.
+const TimeZone = "UTC"
+
 func GetConnection(cfg Config) (*sqlx.DB, error) {
 	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password,
 		cfg.DBName)
-
-	db, err := sqlx.Connect("pgx", dsn)
+	connConfig, err := pgx.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
+
+	db := Open(connConfig)
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 	return db, nil
 }
 
+// Open returns connections to the database of cfg with the session settings the repositories rely on.
+func Open(cfg *pgx.ConnConfig) *sqlx.DB {
+	cfg = cfg.Copy()
+	cfg.RuntimeParams["timezone"] = TimeZone
+	return sqlx.NewDb(stdlib.OpenDB(*cfg), "pgx")
+}
+
 func CloseConnection(db *sqlx.DB) error {
 	return db.Close()
 }
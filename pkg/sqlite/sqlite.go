@@ -0,0 +1,32 @@
+package sqlite
+
+import (
+	"fmt"
+	"github.com/jmoiron/sqlx"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+type Config struct {
+	Path         string        `yaml:"path"`
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+}
+
+// GetConnection opens the database file at cfg.Path, creating it when it does not exist.
+// SQLite allows a single writer, so the pool is limited to one connection to avoid busy errors.
+func GetConnection(cfg Config) (*sqlx.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite", cfg.Path)
+
+	db, err := sqlx.Connect("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+func CloseConnection(db *sqlx.DB) error {
+	return db.Close()
+}